@@ -0,0 +1,403 @@
+package bittrex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// authenticationExpiringMethod is the method that the c2 hub invokes on the
+// client shortly before the authentication of the connection expires.
+const authenticationExpiringMethod = "authenticationExpiring"
+
+// OrderDeltaType represents a type of OrderDelta.
+type OrderDeltaType int
+
+func (t OrderDeltaType) String() string {
+	switch t {
+	case OrderOpened:
+		return "OPEN"
+	case OrderPartiallyFilled:
+		return "PARTIAL"
+	case OrderFilled:
+		return "FILL"
+	case OrderCanceled:
+		return "CANCEL"
+	default:
+		return "<invalid order delta type>"
+	}
+}
+
+const (
+	// OrderOpened means the order was placed.
+	OrderOpened OrderDeltaType = iota
+
+	// OrderPartiallyFilled means part of the order was filled.
+	OrderPartiallyFilled
+
+	// OrderFilled means the order was filled completely.
+	OrderFilled
+
+	// OrderCanceled means the order was canceled.
+	OrderCanceled
+)
+
+// OrderDelta represents a change to one of the account's orders.
+type OrderDelta struct {
+	// Nonce increases with each order delta of the account. The deltas may
+	// be handed over out of order, so use it to tell which one is the latest.
+	Nonce uint
+
+	Type    OrderDeltaType
+	Order   OpenOrder
+	Updated time.Time
+}
+
+// BalanceDelta represents a change to one of the account's balances.
+type BalanceDelta struct {
+	// Nonce increases with each balance delta of the account. The deltas may
+	// be handed over out of order, so use it to tell which one is the latest.
+	Nonce uint
+
+	Balance Balance
+	Updated time.Time
+}
+
+// OrderDeltaHandler processes an order delta.
+type OrderDeltaHandler func(d OrderDelta)
+
+// BalanceDeltaHandler processes a balance delta.
+type BalanceDeltaHandler func(d BalanceDelta)
+
+// accountHandler is a registered order or balance delta handler. Only one of
+// its handler functions is set.
+type accountHandler struct {
+	order   OrderDeltaHandler
+	balance BalanceDeltaHandler
+
+	// inline makes the handler run in the goroutine that processes the
+	// messages. Such a handler must not block.
+	inline bool
+}
+
+// orderDelta converts the delta into an OrderDelta.
+func (d c2OrderDelta) orderDelta() OrderDelta {
+	o := d.Order
+	od := OrderDelta{
+		Nonce: d.Nonce,
+		Type:  OrderDeltaType(d.Type),
+		Order: OpenOrder{
+			OrderUUID:         OrderID(o.OrderUUID),
+			Exchange:          o.Exchange,
+			OrderType:         o.OrderType,
+			Quantity:          o.Quantity,
+			QuantityRemaining: o.QuantityRemaining,
+			Limit:             o.Limit,
+			CommissionPaid:    o.CommissionPaid,
+			Price:             o.Price,
+			Opened:            c2Time(o.Opened),
+			CancelInitiated:   o.CancelInitiated,
+			ImmediateOrCancel: o.ImmediateOrCancel,
+			IsConditional:     o.IsConditional,
+			Condition:         o.Condition,
+			ConditionTarget:   o.ConditionTarget,
+		},
+		Updated: c2Time(o.Updated),
+	}
+
+	if o.PricePerUnit != nil {
+		od.Order.PricePerUnit = *o.PricePerUnit
+	}
+	if o.Closed != nil {
+		closed := c2Time(*o.Closed)
+		od.Order.Closed = &closed
+	}
+
+	return od
+}
+
+// balanceDelta converts the delta into a BalanceDelta.
+func (d c2BalanceDelta) balanceDelta() BalanceDelta {
+	b := d.Delta
+	bd := BalanceDelta{
+		Nonce: d.Nonce,
+		Balance: Balance{
+			Currency:  b.Currency,
+			Balance:   b.Balance,
+			Available: b.Available,
+			Pending:   b.Pending,
+		},
+		Updated: c2Time(b.Updated),
+	}
+
+	if b.CryptoAddress != nil {
+		bd.Balance.CryptoAddress = *b.CryptoAddress
+	}
+
+	return bd
+}
+
+// Authenticate authenticates the websocket connection with the API key and
+// secret, so that Bittrex starts sending the changes to the account's orders
+// and balances. See RegisterOrderDeltaHandler and RegisterBalanceDeltaHandler.
+// The connection is authenticated again after reconnects and whenever Bittrex
+// reports that the authentication is about to expire.
+func (c *Client) Authenticate(errHandler ErrHandler) error {
+	return c.AuthenticateContext(context.Background(), errHandler)
+}
+
+// AuthenticateContext is like Authenticate, but starting the underlying
+// SignalR client and the handshake are bound to the specified context.
+func (c *Client) AuthenticateContext(ctx context.Context, errHandler ErrHandler) error {
+	err := c.websocketReady(ctx, errHandler)
+	if err != nil {
+		return errors.Wrap(err, "underlying signalr client is not ready")
+	}
+
+	err = c.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.subscriptionsMux.Lock()
+	c.authenticated = true
+	c.subscriptionsMux.Unlock()
+
+	return nil
+}
+
+// authenticate signs the challenge that Bittrex hands out with the API secret
+// and sends it back.
+func (c *Client) authenticate(ctx context.Context) error {
+	err := c.PrivateRateLimiter.Wait(ctx)
+	if err != nil {
+		return errors.Wrap(err, "authentication canceled")
+	}
+
+	res, err := c.invoke(ctx, c2Hub, "GetAuthContext", c.APIKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the authentication challenge")
+	}
+
+	var challenge string
+	err = json.Unmarshal(res, &challenge)
+	if err != nil {
+		return errors.Wrap(err, "json unmarshal failed")
+	}
+
+	mac := hmac.New(sha512.New, []byte(c.APISecret))
+	// We ignore the error because the documentation states errors will never be
+	// returned: https://golang.org/pkg/hash/#Hash
+	_, _ = mac.Write([]byte(challenge)) // nolint: gas, gosec
+	signed := hex.EncodeToString(mac.Sum(nil))
+
+	res, err = c.invoke(ctx, c2Hub, "Authenticate", c.APIKey, signed)
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate")
+	}
+
+	var accepted bool
+	err = json.Unmarshal(res, &accepted)
+	if err != nil {
+		return errors.Wrap(err, "json unmarshal failed")
+	}
+	if !accepted {
+		return errors.New("authentication was rejected")
+	}
+
+	return nil
+}
+
+// isAuthenticated indicates if the connection is meant to be authenticated.
+func (c *Client) isAuthenticated() bool {
+	c.subscriptionsMux.Lock()
+	defer c.subscriptionsMux.Unlock()
+	return c.authenticated
+}
+
+// reauthenticate authenticates the connection again, if it was authenticated
+// before, and reports the outcome as a stream event.
+func (c *Client) reauthenticate() {
+	if !c.isAuthenticated() {
+		return
+	}
+
+	ctx, cancel := c.lifetimeContext(resyncTimeout)
+	err := c.authenticate(ctx)
+	cancel()
+	if err != nil {
+		err = errors.Wrap(err, "failed to authenticate again")
+		c.emitStreamEvent(ReauthenticateFailed, "", err)
+		c.reportError(err)
+		return
+	}
+
+	c.emitStreamEvent(Reauthenticated, "", nil)
+}
+
+// RegisterOrderDeltaHandler saves the specified order delta handler to a slice
+// of handlers that will be run against each change to the account's orders.
+// The handler stays registered until the returned registration is
+// unregistered. See Authenticate.
+func (c *Client) RegisterOrderDeltaHandler(h OrderDeltaHandler) *Registration {
+	return c.registerAccountHandler(&accountHandler{order: h})
+}
+
+// RegisterBalanceDeltaHandler saves the specified balance delta handler to a
+// slice of handlers that will be run against each change to the account's
+// balances. The handler stays registered until the returned registration is
+// unregistered. See Authenticate.
+func (c *Client) RegisterBalanceDeltaHandler(h BalanceDeltaHandler) *Registration {
+	return c.registerAccountHandler(&accountHandler{balance: h})
+}
+
+// registerAccountHandler adds the account handler.
+func (c *Client) registerAccountHandler(ah *accountHandler) *Registration {
+	c.accountHandlersMux.Lock()
+	defer c.accountHandlersMux.Unlock()
+	c.accountHandlers = append(c.accountHandlers, ah)
+
+	return newRegistration(func() {
+		c.accountHandlersMux.Lock()
+		defer c.accountHandlersMux.Unlock()
+
+		for i, other := range c.accountHandlers {
+			if other == ah {
+				c.accountHandlers = append(c.accountHandlers[:i:i], c.accountHandlers[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// OrderDeltas returns a channel that receives the changes to the account's
+// orders. See Authenticate. The channel is closed once the context is done or
+// the client is closed.
+func (c *Client) OrderDeltas(ctx context.Context) (<-chan OrderDelta, error) {
+	deltas := make(chan OrderDelta)
+	buf := newStreamBuffer()
+
+	err := c.runBufferedStream(ctx, &accountHandler{
+		inline: true,
+		order:  func(d OrderDelta) { buf.add(d) },
+	}, func(stop <-chan struct{}) {
+		defer close(deltas)
+		buf.forward(stop, func(v interface{}) bool {
+			select {
+			case deltas <- v.(OrderDelta):
+				return true
+			case <-stop:
+				return false
+			}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deltas, nil
+}
+
+// BalanceDeltas returns a channel that receives the changes to the account's
+// balances. See Authenticate. The channel is closed once the context is done
+// or the client is closed.
+func (c *Client) BalanceDeltas(ctx context.Context) (<-chan BalanceDelta, error) {
+	deltas := make(chan BalanceDelta)
+	buf := newStreamBuffer()
+
+	err := c.runBufferedStream(ctx, &accountHandler{
+		inline:  true,
+		balance: func(d BalanceDelta) { buf.add(d) },
+	}, func(stop <-chan struct{}) {
+		defer close(deltas)
+		buf.forward(stop, func(v interface{}) bool {
+			select {
+			case deltas <- v.(BalanceDelta):
+				return true
+			case <-stop:
+				return false
+			}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deltas, nil
+}
+
+// runBufferedStream registers the account handler and runs forward until the
+// context is done or the client is closed, after which the handler is
+// unregistered again.
+func (c *Client) runBufferedStream(ctx context.Context, ah *accountHandler, forward func(stop <-chan struct{})) error {
+	err := c.streamReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	reg := c.registerAccountHandler(ah)
+	stop := make(chan struct{})
+
+	if !c.spawn(func() {
+		defer reg.Unregister()
+		forward(stop)
+	}) {
+		reg.Unregister()
+		return errors.New("client is closed")
+	}
+
+	return c.runStream(ctx, stop, func() {})
+}
+
+// processOrderDelta decodes the argument of a uO message and runs the order
+// delta handlers against it.
+func (c *Client) processOrderDelta(arg interface{}, errHandler ErrHandler) {
+	var d c2OrderDelta
+	err := decodeC2Arg(arg, &d)
+	if err != nil {
+		go errHandler(errors.Wrap(err, "failed to decode the order delta"))
+		return
+	}
+	od := d.orderDelta()
+
+	c.accountHandlersMux.Lock()
+	defer c.accountHandlersMux.Unlock()
+	for _, ah := range c.accountHandlers {
+		switch {
+		case ah.order == nil:
+		case ah.inline:
+			ah.order(od)
+		default:
+			go ah.order(od)
+		}
+	}
+}
+
+// processBalanceDelta decodes the argument of a uB message and runs the
+// balance delta handlers against it.
+func (c *Client) processBalanceDelta(arg interface{}, errHandler ErrHandler) {
+	var d c2BalanceDelta
+	err := decodeC2Arg(arg, &d)
+	if err != nil {
+		go errHandler(errors.Wrap(err, "failed to decode the balance delta"))
+		return
+	}
+	bd := d.balanceDelta()
+
+	c.accountHandlersMux.Lock()
+	defer c.accountHandlersMux.Unlock()
+	for _, ah := range c.accountHandlers {
+		switch {
+		case ah.balance == nil:
+		case ah.inline:
+			ah.balance(bd)
+		default:
+			go ah.balance(bd)
+		}
+	}
+}
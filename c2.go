@@ -0,0 +1,308 @@
+package bittrex
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// c2Hub is the name of the hub that serves the compressed v2 websocket API.
+const c2Hub = "c2"
+
+// The methods that the c2 hub invokes on the client. Their only argument is a
+// payload in the c2 format.
+const (
+	c2ExchangeDeltasMethod    = "uE"
+	c2SummaryDeltasMethod     = "uS"
+	c2LiteSummaryDeltasMethod = "uL"
+	c2OrderDeltaMethod        = "uO"
+	c2BalanceDeltaMethod      = "uB"
+)
+
+// c2Keys maps the minified keys of the c2 payloads to their full names.
+var c2Keys = map[string]string{
+	"A":  "Ask",
+	"a":  "Available",
+	"B":  "Bid",
+	"b":  "Balance",
+	"C":  "Closed",
+	"c":  "Currency",
+	"CI": "CancelInitiated",
+	"D":  "Deltas",
+	"d":  "Delta",
+	"DT": "OrderDeltaType",
+	"E":  "Exchange",
+	"e":  "ExchangeDeltaType",
+	"F":  "FillType",
+	"FI": "FillId",
+	"f":  "Fills",
+	"G":  "OpenBuyOrders",
+	"g":  "OpenSellOrders",
+	"H":  "High",
+	"h":  "AutoSell",
+	"I":  "Id",
+	"i":  "IsOpen",
+	"J":  "Condition",
+	"j":  "ConditionTarget",
+	"K":  "ImmediateOrCancel",
+	"k":  "IsConditional",
+	"L":  "Low",
+	"l":  "Last",
+	"M":  "MarketName",
+	"m":  "BaseVolume",
+	"N":  "Nonce",
+	"n":  "CommissionPaid",
+	"O":  "Orders",
+	"o":  "Order",
+	"OT": "OrderType",
+	"OU": "OrderUuid",
+	"P":  "Price",
+	"p":  "CryptoAddress",
+	"PD": "PrevDay",
+	"PU": "PricePerUnit",
+	"Q":  "Quantity",
+	"q":  "QuantityRemaining",
+	"R":  "Rate",
+	"r":  "Requested",
+	"S":  "Sells",
+	"s":  "Summaries",
+	"T":  "TimeStamp",
+	"t":  "Total",
+	"TY": "Type",
+	"U":  "Uuid",
+	"u":  "Updated",
+	"V":  "Volume",
+	"W":  "AccountId",
+	"w":  "AccountUuid",
+	"X":  "Limit",
+	"x":  "Created",
+	"Y":  "Opened",
+	"y":  "State",
+	"Z":  "Buys",
+	"z":  "Pending",
+}
+
+// decodeC2Payload decodes a c2 payload into v. The payload is the base64
+// encoding of raw deflate compressed JSON with minified keys, which are
+// expanded to their full names before v is unmarshaled.
+func decodeC2Payload(payload string, v interface{}) error {
+	compressed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return errors.Wrap(err, "base64 decode failed")
+	}
+
+	r := flate.NewReader(bytes.NewReader(compressed))
+	data, err := ioutil.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return errors.Wrap(err, "inflate failed")
+	}
+
+	var raw interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return errors.Wrap(err, "json unmarshal failed")
+	}
+
+	data, err = json.Marshal(expandC2Keys(raw))
+	if err != nil {
+		return errors.Wrap(err, "json marshal failed")
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return nil
+}
+
+// decodeC2Arg decodes the argument of a c2 hub message, which must be a c2
+// payload, into v.
+func decodeC2Arg(arg interface{}, v interface{}) error {
+	payload, ok := arg.(string)
+	if !ok {
+		return errors.Errorf("expected a c2 payload, got %T", arg)
+	}
+	return decodeC2Payload(payload, v)
+}
+
+// expandC2Keys replaces the minified keys of the decoded JSON value with their
+// full names. Unknown keys are left alone.
+func expandC2Keys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if full, ok := c2Keys[k]; ok {
+				k = full
+			}
+			expanded[k] = expandC2Keys(e)
+		}
+		return expanded
+	case []interface{}:
+		for i, e := range v {
+			v[i] = expandC2Keys(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// c2Time converts a c2 timestamp, which is the number of milliseconds since
+// the Unix epoch, into a time.Time object.
+func c2Time(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// c2ExchangeDeltas is the payload of a uE message.
+type c2ExchangeDeltas struct {
+	MarketName string
+	Nonce      uint
+	Buys       []tradeOrder
+	Sells      []tradeOrder
+	Fills      []c2Fill
+}
+
+// c2Fill is a fill of a uE message.
+type c2Fill struct {
+	FillID    int64 `json:"FillId"`
+	OrderType string
+	Rate      float64
+	Quantity  float64
+	TimeStamp int64
+}
+
+// exchangeUpdate converts the deltas into the form that the exchange updates of
+// the legacy hub had.
+func (d c2ExchangeDeltas) exchangeUpdate() exchangeUpdate {
+	eu := exchangeUpdate{
+		MarketName: d.MarketName,
+		Nounce:     d.Nonce,
+		Buys:       d.Buys,
+		Sells:      d.Sells,
+	}
+	for _, f := range d.Fills {
+		eu.Fills = append(eu.Fills, tradeFill{
+			OrderType: f.OrderType,
+			Rate:      f.Rate,
+			Quantity:  f.Quantity,
+			at:        c2Time(f.TimeStamp),
+		})
+	}
+	return eu
+}
+
+// c2ExchangeState is the result of QueryExchangeState.
+type c2ExchangeState struct {
+	MarketName string
+	Nonce      uint
+	Buys       []OrderBookEntry
+	Sells      []OrderBookEntry
+}
+
+// exchangeState converts the state into the form that the legacy hub returned.
+func (s c2ExchangeState) exchangeState() exchangeState {
+	return exchangeState{
+		MarketName: s.MarketName,
+		Nounce:     s.Nonce,
+		Buys:       s.Buys,
+		Sells:      s.Sells,
+	}
+}
+
+// c2SummaryDeltas is the payload of a uS message and, with Summaries instead
+// of Deltas, the result of QuerySummaryState.
+type c2SummaryDeltas struct {
+	Nonce     uint
+	Deltas    []c2Summary
+	Summaries []c2Summary
+}
+
+// c2Summary is the summary of a market in a uS message.
+type c2Summary struct {
+	MarketName     string
+	High           float64
+	Low            float64
+	Volume         float64
+	Last           float64
+	BaseVolume     float64
+	TimeStamp      int64
+	Bid            float64
+	Ask            float64
+	OpenBuyOrders  int
+	OpenSellOrders int
+	PrevDay        float64
+	Created        int64
+}
+
+// c2LiteSummaryDeltas is the payload of a uL message.
+type c2LiteSummaryDeltas struct {
+	Nonce  uint
+	Deltas []c2LiteSummary
+}
+
+// c2LiteSummary is the summary of a market in a uL message.
+type c2LiteSummary struct {
+	MarketName string
+	Last       float64
+	BaseVolume float64
+}
+
+// c2OrderDelta is the payload of a uO message.
+type c2OrderDelta struct {
+	AccountUUID string `json:"AccountUuid"`
+	Nonce       uint
+	Type        int
+	Order       c2Order
+}
+
+// c2Order is the order of a uO message.
+type c2Order struct {
+	UUID              string `json:"Uuid"`
+	ID                int64  `json:"Id"`
+	OrderUUID         string `json:"OrderUuid"`
+	Exchange          string
+	OrderType         string
+	Quantity          float64
+	QuantityRemaining float64
+	Limit             float64
+	CommissionPaid    float64
+	Price             float64
+	PricePerUnit      *float64
+	Opened            int64
+	Closed            *int64
+	IsOpen            bool
+	CancelInitiated   bool
+	ImmediateOrCancel bool
+	IsConditional     bool
+	Condition         string
+	ConditionTarget   *float64
+	Updated           int64
+}
+
+// c2BalanceDelta is the payload of a uB message.
+type c2BalanceDelta struct {
+	Nonce uint
+	Delta c2Balance
+}
+
+// c2Balance is the balance of a uB message.
+type c2Balance struct {
+	UUID          string `json:"Uuid"`
+	AccountID     int64  `json:"AccountId"`
+	Currency      string
+	Balance       float64
+	Available     float64
+	Pending       float64
+	CryptoAddress *string
+	Requested     bool
+	Updated       int64
+	AutoSell      *bool
+}
@@ -0,0 +1,179 @@
+package bittrex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeC2Payload(t *testing.T) {
+	pricePerUnit := 0.00002
+	closed := int64(1516456455310)
+
+	cases := map[string]struct {
+		payload string
+		into    func() interface{}
+		exp     interface{}
+		wantErr string
+	}{
+		"exchange deltas": {
+			payload: `{"M":"BTC-LTC","N":12,"Z":[{"TY":0,"R":0.5,"Q":2}],"S":[{"TY":1,"R":0.7,"Q":0}],` +
+				`"f":[{"FI":42,"OT":"BUY","R":0.6,"Q":1.5,"T":1516456455310}]}`,
+			into: func() interface{} { return &c2ExchangeDeltas{} },
+			exp: &c2ExchangeDeltas{
+				MarketName: "BTC-LTC",
+				Nonce:      12,
+				Buys:       []tradeOrder{{Type: 0, Rate: 0.5, Quantity: 2}},
+				Sells:      []tradeOrder{{Type: 1, Rate: 0.7, Quantity: 0}},
+				Fills:      []c2Fill{{FillID: 42, OrderType: "BUY", Rate: 0.6, Quantity: 1.5, TimeStamp: 1516456455310}},
+			},
+		},
+		"exchange state": {
+			payload: `{"M":"BTC-LTC","N":10,"Z":[{"Q":1,"R":0.9}],"S":[{"Q":3,"R":1.1}],` +
+				`"f":[{"I":1,"T":1516456455310,"Q":1,"P":0.9,"t":0.9,"F":"FILL","OT":"BUY","U":"x"}]}`,
+			into: func() interface{} { return &c2ExchangeState{} },
+			exp: &c2ExchangeState{
+				MarketName: "BTC-LTC",
+				Nonce:      10,
+				Buys:       []OrderBookEntry{{Quantity: 1, Rate: 0.9}},
+				Sells:      []OrderBookEntry{{Quantity: 3, Rate: 1.1}},
+			},
+		},
+		"summary deltas": {
+			payload: `{"N":5,"D":[{"M":"BTC-LTC","H":0.02,"L":0.01,"V":100,"l":0.015,"m":1.5,"T":1516456455310,` +
+				`"B":0.014,"A":0.016,"G":12,"g":34,"PD":0.012,"x":1399912345000}]}`,
+			into: func() interface{} { return &c2SummaryDeltas{} },
+			exp: &c2SummaryDeltas{
+				Nonce: 5,
+				Deltas: []c2Summary{{
+					MarketName:     "BTC-LTC",
+					High:           0.02,
+					Low:            0.01,
+					Volume:         100,
+					Last:           0.015,
+					BaseVolume:     1.5,
+					TimeStamp:      1516456455310,
+					Bid:            0.014,
+					Ask:            0.016,
+					OpenBuyOrders:  12,
+					OpenSellOrders: 34,
+					PrevDay:        0.012,
+					Created:        1399912345000,
+				}},
+			},
+		},
+		"lite summary deltas": {
+			payload: `{"N":6,"D":[{"M":"BTC-LTC","l":0.015,"m":1.5}]}`,
+			into:    func() interface{} { return &c2LiteSummaryDeltas{} },
+			exp: &c2LiteSummaryDeltas{
+				Nonce:  6,
+				Deltas: []c2LiteSummary{{MarketName: "BTC-LTC", Last: 0.015, BaseVolume: 1.5}},
+			},
+		},
+		"order delta": {
+			payload: `{"w":"acct","N":3,"TY":2,"o":{"U":"u1","I":7,"OU":"o1","E":"BTC-LTC","OT":"LIMIT_BUY",` +
+				`"Q":5,"q":0,"X":0.00002,"n":0.0000025,"P":0.0001,"PU":0.00002,"Y":1516456455300,` +
+				`"C":1516456455310,"i":false,"CI":false,"K":false,"k":false,"J":"NONE","j":null,"u":1516456455310}}`,
+			into: func() interface{} { return &c2OrderDelta{} },
+			exp: &c2OrderDelta{
+				AccountUUID: "acct",
+				Nonce:       3,
+				Type:        2,
+				Order: c2Order{
+					UUID:           "u1",
+					ID:             7,
+					OrderUUID:      "o1",
+					Exchange:       "BTC-LTC",
+					OrderType:      "LIMIT_BUY",
+					Quantity:       5,
+					Limit:          0.00002,
+					CommissionPaid: 0.0000025,
+					Price:          0.0001,
+					PricePerUnit:   &pricePerUnit,
+					Opened:         1516456455300,
+					Closed:         &closed,
+					Condition:      "NONE",
+					Updated:        1516456455310,
+				},
+			},
+		},
+		"balance delta": {
+			payload: `{"N":4,"d":{"U":"u2","W":9,"c":"BTC","b":1.5,"a":1.25,"z":0.25,"p":null,"r":false,"u":1516456455310,"h":null}}`,
+			into:    func() interface{} { return &c2BalanceDelta{} },
+			exp: &c2BalanceDelta{
+				Nonce: 4,
+				Delta: c2Balance{
+					UUID:      "u2",
+					AccountID: 9,
+					Currency:  "BTC",
+					Balance:   1.5,
+					Available: 1.25,
+					Pending:   0.25,
+					Updated:   1516456455310,
+				},
+			},
+		},
+		"invalid json": {
+			payload: `{"M":`,
+			into:    func() interface{} { return &c2ExchangeDeltas{} },
+			wantErr: "json unmarshal failed",
+		},
+	}
+
+	for id, tc := range cases {
+		payload, err := encodeC2Data([]byte(tc.payload))
+		ok(t, id, err)
+
+		act := tc.into()
+		err = decodeC2Payload(payload, act)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+			continue
+		}
+		ok(t, id, err)
+		equals(t, id, tc.exp, act)
+	}
+}
+
+func TestDecodeC2Payload_Invalid(t *testing.T) {
+	cases := map[string]struct {
+		arg     interface{}
+		wantErr string
+	}{
+		"not a string": {
+			arg:     map[string]interface{}{"M": "BTC-LTC"},
+			wantErr: "expected a c2 payload, got map[string]interface {}",
+		},
+		"not base64": {
+			arg:     "not base64!",
+			wantErr: "base64 decode failed",
+		},
+		"not deflated": {
+			arg:     "aGVsbG8gd29ybGQ=",
+			wantErr: "inflate failed",
+		},
+	}
+
+	for id, tc := range cases {
+		var deltas c2ExchangeDeltas
+		errMatches(t, id, decodeC2Arg(tc.arg, &deltas), tc.wantErr)
+	}
+}
+
+func TestC2ExchangeDeltas_exchangeUpdate(t *testing.T) {
+	d := c2ExchangeDeltas{
+		MarketName: "BTC-LTC",
+		Nonce:      12,
+		Buys:       []tradeOrder{{Type: 0, Rate: 0.5, Quantity: 2}},
+		Fills:      []c2Fill{{FillID: 42, OrderType: "SELL", Rate: 0.6, Quantity: 1.5, TimeStamp: 1516456455310}},
+	}
+
+	eu := d.exchangeUpdate()
+	equals(t, "market", "BTC-LTC", eu.MarketName)
+	equals(t, "nonce", uint(12), eu.Nounce)
+	equals(t, "buys", d.Buys, eu.Buys)
+	equals(t, "fills", 1, len(eu.Fills))
+
+	at, err := eu.Fills[0].time()
+	ok(t, "time", err)
+	equals(t, "time", time.Date(2018, 1, 20, 13, 54, 15, 310000000, time.UTC), at)
+}
@@ -2,6 +2,7 @@ package bittrex
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -14,7 +15,24 @@ type Candle struct {
 	High   float64
 	Low    float64
 	Close  float64
-	Volume float64
+
+	// Volume is the quantity traded, in the market currency. BuyVolume and
+	// SellVolume split it by the type of the trades.
+	Volume     float64
+	BuyVolume  float64
+	SellVolume float64
+
+	// BaseVolume is the value traded, in the base currency.
+	BaseVolume float64
+
+	// VWAP is the volume weighted average price, which is zero if nothing was
+	// traded.
+	VWAP float64
+
+	// Trades is the number of trades. It is zero for candles that are
+	// converted from ticks, which don't include it, as are BuyVolume and
+	// SellVolume.
+	Trades int
 }
 
 func (candle Candle) String() string {
@@ -26,3 +44,186 @@ func (candle Candle) String() string {
 
 	return fmt.Sprintf("%s: %s|O:%s|H:%s|L:%s|C:%s|V:%s", candle.Market, candle.Time.Format(time.RFC3339), o, h, l, c, v)
 }
+
+// candleBuckets builds the candles of an interval from trades. Each trade goes
+// into the bucket that its own time falls in, and the buckets are aligned to
+// multiples of the interval in UTC, so a one minute candle always starts on
+// the minute.
+type candleBuckets struct {
+	interval time.Duration
+
+	// buckets holds the candles that are still being built.
+	buckets map[candleKey]*candleBucket
+
+	// watermark is the start of the earliest bucket that isn't finalized yet.
+	// The trades of earlier buckets come too late and are dropped.
+	watermark time.Time
+}
+
+// candleKey identifies the bucket of a market.
+type candleKey struct {
+	market string
+	start  time.Time
+}
+
+// candleBucket is a candle that is being built, along with the times of its
+// first and last trades, which decide its open and close prices.
+type candleBucket struct {
+	candle      Candle
+	first, last time.Time
+}
+
+// newCandleBuckets creates the buckets of the specified interval.
+func newCandleBuckets(interval time.Duration) *candleBuckets {
+	return &candleBuckets{
+		interval: interval,
+		buckets:  make(map[candleKey]*candleBucket),
+	}
+}
+
+// add adds the trade to the candle of its bucket. It reports false if the
+// bucket was already finalized, in which case the trade is dropped.
+func (cb *candleBuckets) add(t Trade) bool {
+	start := t.Time.UTC().Truncate(cb.interval)
+	if start.Before(cb.watermark) {
+		return false
+	}
+
+	key := candleKey{market: t.Market(), start: start}
+	b, ok := cb.buckets[key]
+	if !ok {
+		b = &candleBucket{
+			candle: Candle{
+				Market: key.market,
+				Time:   start,
+				Open:   t.Price,
+				High:   t.Price,
+				Low:    t.Price,
+				Close:  t.Price,
+			},
+			first: t.Time,
+			last:  t.Time,
+		}
+		b.candle.addVolume(t)
+		cb.buckets[key] = b
+		return true
+	}
+
+	// Trades may arrive out of order, so the open and close prices go by the
+	// time of the trades rather than their order.
+	if t.Time.Before(b.first) {
+		b.candle.Open = t.Price
+		b.first = t.Time
+	}
+	if !t.Time.Before(b.last) {
+		b.candle.Close = t.Price
+		b.last = t.Time
+	}
+
+	if t.Price > b.candle.High {
+		b.candle.High = t.Price
+	}
+	if t.Price < b.candle.Low {
+		b.candle.Low = t.Price
+	}
+	b.candle.addVolume(t)
+
+	return true
+}
+
+// addVolume adds the volume of the trade to the candle.
+func (candle *Candle) addVolume(t Trade) {
+	candle.Trades++
+	candle.Volume += t.Quantity
+	candle.BaseVolume += t.Price * t.Quantity
+
+	switch t.Type {
+	case BuyType:
+		candle.BuyVolume += t.Quantity
+	case SellType:
+		candle.SellVolume += t.Quantity
+	}
+
+	candle.updateVWAP()
+}
+
+// updateVWAP calculates the volume weighted average price of the candle from
+// its volumes.
+func (candle *Candle) updateVWAP() {
+	candle.VWAP = 0
+	if candle.Volume > 0 {
+		candle.VWAP = candle.BaseVolume / candle.Volume
+	}
+}
+
+// merge adds the next candle of the same market, which directly follows this
+// one, to this one.
+func (candle *Candle) merge(next Candle) {
+	if next.High > candle.High {
+		candle.High = next.High
+	}
+	if next.Low < candle.Low {
+		candle.Low = next.Low
+	}
+	candle.Close = next.Close
+	candle.Volume += next.Volume
+	candle.BuyVolume += next.BuyVolume
+	candle.SellVolume += next.SellVolume
+	candle.BaseVolume += next.BaseVolume
+	candle.Trades += next.Trades
+	candle.updateVWAP()
+}
+
+// sortCandles orders the candles by time and market.
+func sortCandles(candles []Candle) {
+	sort.Slice(candles, func(i, j int) bool {
+		if !candles[i].Time.Equal(candles[j].Time) {
+			return candles[i].Time.Before(candles[j].Time)
+		}
+		return candles[i].Market < candles[j].Market
+	})
+}
+
+// finalize returns the candles of the buckets that ended at least grace before
+// now, ordered by time and market. Trades of those buckets that arrive later
+// are dropped.
+func (cb *candleBuckets) finalize(now time.Time, grace time.Duration) []Candle {
+	return cb.finalizeBefore(now.UTC().Add(-grace).Truncate(cb.interval))
+}
+
+// finalizeBefore returns the candles of the buckets that start before the
+// watermark, ordered by time and market.
+func (cb *candleBuckets) finalizeBefore(watermark time.Time) []Candle {
+	if watermark.After(cb.watermark) {
+		cb.watermark = watermark
+	}
+
+	var finished []Candle
+	for key, b := range cb.buckets {
+		if key.start.Before(cb.watermark) {
+			finished = append(finished, b.candle)
+			delete(cb.buckets, key)
+		}
+	}
+	sortCandles(finished)
+
+	return finished
+}
+
+// end returns the end of the latest bucket, or the watermark if there are no
+// buckets.
+func (cb *candleBuckets) end() time.Time {
+	end := cb.watermark
+	for key := range cb.buckets {
+		if e := key.start.Add(cb.interval); e.After(end) {
+			end = e
+		}
+	}
+	return end
+}
+
+// nextFinalization returns the time after now at which the next bucket can be
+// finalized.
+func (cb *candleBuckets) nextFinalization(now time.Time, grace time.Duration) time.Time {
+	return now.UTC().Add(-grace).Truncate(cb.interval).Add(cb.interval).Add(grace)
+}
@@ -0,0 +1,415 @@
+package bittrex
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CandleAggregator builds the candles of several intervals from a single
+// stream of trades. Only the candles of the lowest interval are built from
+// the trades; the candles of the higher intervals are built from those. All
+// candles start on multiples of their interval in UTC.
+//
+// It can be fed by a Client, see AggregateCandles, or on its own: call Add for
+// each trade and Advance to finalize the candles whose time has come. When
+// replaying trades, such as from a file, advance to the time of the trades and
+// call Flush at the end.
+type CandleAggregator struct {
+	// GracePeriod is how long a candle of the lowest interval waits for late
+	// trades after its interval ends, before it is finalized.
+	GracePeriod time.Duration
+
+	// FillGaps makes a market produce a candle for every interval once it has
+	// had a trade. An interval without any trades gets a flat candle at the
+	// previous close, without any volume.
+	FillGaps bool
+
+	// base builds the candles of the lowest interval and rollups build the
+	// candles of the others, in ascending order of interval. last holds the
+	// latest candle of the lowest interval of each market, which the gaps are
+	// filled from. They are protected by mux.
+	base    *candleBuckets
+	rollups []*candleRollup
+	last    map[string]Candle
+	mux     sync.Mutex
+
+	// emitMux keeps the candles that are finalized by concurrent calls from
+	// being handed over out of order.
+	emitMux sync.Mutex
+
+	// handlers holds all of the registered candle handlers.
+	handlers    []*aggregatorHandler
+	handlersMux sync.Mutex
+}
+
+// aggregatorHandler is a candle handler that is registered for an interval.
+type aggregatorHandler struct {
+	interval time.Duration
+	h        CandleHandler
+}
+
+// candleRollup builds the candles of an interval from the finished candles of
+// a lower one.
+type candleRollup struct {
+	interval time.Duration
+	candles  map[candleKey]*Candle
+}
+
+// add merges the candle into the candle of its bucket. The candles of each
+// market must be added in order.
+func (r *candleRollup) add(candle Candle) {
+	start := candle.Time.Truncate(r.interval)
+	key := candleKey{market: candle.Market, start: start}
+
+	if rc, ok := r.candles[key]; ok {
+		rc.merge(candle)
+		return
+	}
+
+	candle.Time = start
+	r.candles[key] = &candle
+}
+
+// finalizeBefore returns the candles that end at or before the watermark,
+// ordered by time and market.
+func (r *candleRollup) finalizeBefore(watermark time.Time) []Candle {
+	var finished []Candle
+	for key, candle := range r.candles {
+		if !key.start.Add(r.interval).After(watermark) {
+			finished = append(finished, *candle)
+			delete(r.candles, key)
+		}
+	}
+	sortCandles(finished)
+
+	return finished
+}
+
+// end returns the end of the latest candle, or the zero time if there are no
+// candles.
+func (r *candleRollup) end() time.Time {
+	var end time.Time
+	for key := range r.candles {
+		if e := key.start.Add(r.interval); e.After(end) {
+			end = e
+		}
+	}
+	return end
+}
+
+// intervalCandles holds finished candles of an interval.
+type intervalCandles struct {
+	interval time.Duration
+	candles  []Candle
+}
+
+// NewCandleAggregator creates a candle aggregator for the specified intervals.
+// Each interval must be a multiple of the lowest one.
+func NewCandleAggregator(intervals ...time.Duration) (*CandleAggregator, error) {
+	if len(intervals) == 0 {
+		return nil, errors.New("no intervals specified")
+	}
+
+	sorted := append([]time.Duration(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	lowest := sorted[0]
+	if lowest <= 0 {
+		return nil, errors.Errorf("invalid interval: %s", lowest)
+	}
+
+	a := &CandleAggregator{
+		GracePeriod: DefaultCandleGracePeriod,
+		base:        newCandleBuckets(lowest),
+	}
+	for i, interval := range sorted[1:] {
+		if interval == sorted[i] {
+			continue
+		}
+		if interval%lowest != 0 {
+			return nil, errors.Errorf("interval %s is not a multiple of %s", interval, lowest)
+		}
+		a.rollups = append(a.rollups, &candleRollup{
+			interval: interval,
+			candles:  make(map[candleKey]*Candle),
+		})
+	}
+
+	return a, nil
+}
+
+// Intervals returns the intervals of the aggregator in ascending order.
+func (a *CandleAggregator) Intervals() []time.Duration {
+	intervals := []time.Duration{a.base.interval}
+	for _, r := range a.rollups {
+		intervals = append(intervals, r.interval)
+	}
+	return intervals
+}
+
+// Register saves the specified candle handler to a slice of handlers that
+// will be run against each finished candle of the specified interval, in the
+// goroutine that finalizes it. The handler stays registered until the returned
+// registration is unregistered.
+func (a *CandleAggregator) Register(interval time.Duration, h CandleHandler) (*Registration, error) {
+	known := false
+	for _, i := range a.Intervals() {
+		known = known || i == interval
+	}
+	if !known {
+		return nil, errors.Errorf("interval %s is not aggregated", interval)
+	}
+
+	ah := &aggregatorHandler{interval: interval, h: h}
+
+	a.handlersMux.Lock()
+	defer a.handlersMux.Unlock()
+	a.handlers = append(a.handlers, ah)
+
+	return newRegistration(func() {
+		a.handlersMux.Lock()
+		defer a.handlersMux.Unlock()
+
+		for i, other := range a.handlers {
+			if other == ah {
+				a.handlers = append(a.handlers[:i:i], a.handlers[i+1:]...)
+				return
+			}
+		}
+	}), nil
+}
+
+// Add adds the trade to the candle that its time falls in. It reports false if
+// that candle was already finalized, in which case the trade is dropped.
+func (a *CandleAggregator) Add(t Trade) bool {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.base.add(t)
+}
+
+// Advance finalizes the candles of the lowest interval that ended at least
+// GracePeriod before now, and the candles of the higher intervals that are
+// made up of finalized candles only, and runs the handlers against them.
+func (a *CandleAggregator) Advance(now time.Time) {
+	a.emitMux.Lock()
+	defer a.emitMux.Unlock()
+
+	a.mux.Lock()
+	finished := a.finalizeBefore(now.UTC().Add(-a.GracePeriod).Truncate(a.base.interval))
+	a.mux.Unlock()
+
+	a.emit(finished)
+}
+
+// Flush finalizes all candles, including the ones whose interval hasn't ended
+// yet, and runs the handlers against them. Trades that are added afterwards
+// only count if they are later than all the flushed candles, of any interval,
+// so that no candle is emitted twice. Add reports false for the others.
+func (a *CandleAggregator) Flush() {
+	a.emitMux.Lock()
+	defer a.emitMux.Unlock()
+
+	a.mux.Lock()
+	finished := a.finalizeBefore(a.end())
+	a.mux.Unlock()
+
+	a.emit(finished)
+}
+
+// end returns the end of the latest candle of any interval, including the
+// candles of the higher intervals that the unfinished candles of the lowest
+// one will go into, or the zero time if there are no candles. The mutex must
+// be held by the caller.
+func (a *CandleAggregator) end() time.Time {
+	var end time.Time
+	later := func(t time.Time) {
+		if t.After(end) {
+			end = t
+		}
+	}
+
+	for key := range a.base.buckets {
+		later(key.start.Add(a.base.interval))
+		for _, r := range a.rollups {
+			later(key.start.Truncate(r.interval).Add(r.interval))
+		}
+	}
+	for _, r := range a.rollups {
+		later(r.end())
+	}
+
+	return end
+}
+
+// finalizeBefore finalizes the candles that end at or before the watermark,
+// which must be a multiple of the lowest interval. The mutex must be held by
+// the caller.
+func (a *CandleAggregator) finalizeBefore(watermark time.Time) []intervalCandles {
+	base := a.base.finalizeBefore(watermark)
+	if a.FillGaps {
+		base = a.fill(base)
+	}
+	finished := []intervalCandles{{interval: a.base.interval, candles: base}}
+
+	for _, r := range a.rollups {
+		for _, candle := range base {
+			r.add(candle)
+		}
+		finished = append(finished, intervalCandles{
+			interval: r.interval,
+			candles:  r.finalizeBefore(a.base.watermark),
+		})
+	}
+
+	return finished
+}
+
+// fill adds a flat candle for each interval before the watermark of the
+// lowest interval that a market which has had a trade has no candle for. The
+// candles must be ordered by time and market, and the mutex must be held by
+// the caller.
+func (a *CandleAggregator) fill(candles []Candle) []Candle {
+	if a.last == nil {
+		a.last = make(map[string]Candle)
+	}
+
+	interval := a.base.interval
+	var filled []Candle
+	fillUntil := func(market string, end time.Time) {
+		last, ok := a.last[market]
+		if !ok {
+			return
+		}
+		for t := last.Time.Add(interval); t.Before(end); t = t.Add(interval) {
+			last = Candle{
+				Market: market,
+				Time:   t,
+				Open:   last.Close,
+				High:   last.Close,
+				Low:    last.Close,
+				Close:  last.Close,
+			}
+			filled = append(filled, last)
+		}
+		a.last[market] = last
+	}
+
+	for _, candle := range candles {
+		fillUntil(candle.Market, candle.Time)
+		filled = append(filled, candle)
+		a.last[candle.Market] = candle
+	}
+	for market := range a.last {
+		fillUntil(market, a.base.watermark)
+	}
+	sortCandles(filled)
+
+	return filled
+}
+
+// emit runs the handlers of each interval against its finished candles.
+func (a *CandleAggregator) emit(finished []intervalCandles) {
+	a.handlersMux.Lock()
+	handlers := append([]*aggregatorHandler(nil), a.handlers...)
+	a.handlersMux.Unlock()
+
+	for _, ic := range finished {
+		for _, candle := range ic.candles {
+			for _, ah := range handlers {
+				if ah.interval == ic.interval {
+					ah.h(candle)
+				}
+			}
+		}
+	}
+}
+
+// nextAdvance returns the time after now at which the next candle can be
+// finalized.
+func (a *CandleAggregator) nextAdvance(now time.Time) time.Time {
+	return a.base.nextFinalization(now, a.GracePeriod)
+}
+
+// AggregateCandles feeds the trades of the specified markets, or of all
+// markets if none are specified, to the candle aggregator and advances it as
+// time goes by, until the context is done or the client is closed. The markets
+// have to be subscribed to separately.
+func (c *Client) AggregateCandles(ctx context.Context, a *CandleAggregator, markets ...string) error {
+	err := c.streamReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	if !c.runCandleAggregator(a, markets, stop, func() {}) {
+		return errors.New("client is closed")
+	}
+
+	return c.runStream(ctx, stop, func() {})
+}
+
+// runCandleAggregator feeds the trades of the markets, or of all markets if
+// none are specified, to the candle aggregator and advances it at the end of
+// each of its lowest intervals. Once the client or stop is closed, this stops
+// and finish is called. It reports false if the client is already closed, in
+// which case nothing is started.
+func (c *Client) runCandleAggregator(a *CandleAggregator, markets []string, stop <-chan struct{}, finish func()) bool {
+	done := c.closing()
+
+	// Register a handler to funnel each trade to a trades channel. The trades
+	// are delivered in order, so that few of them come late. Trades that
+	// arrive after processing stops are dropped.
+	trades := make(chan Trade)
+	th := c.register(func(t Trade) {
+		select {
+		case trades <- t:
+		case <-done:
+		case <-stop:
+		}
+	}, true, markets...)
+
+	// Start a goroutine that adds each trade to its candle as it comes in.
+	started := c.spawn(func() {
+		defer c.unregister(th)
+
+		for {
+			select {
+			case t := <-trades:
+				a.Add(t)
+			case <-done:
+				return
+			case <-stop:
+				return
+			}
+		}
+	})
+	if !started {
+		c.unregister(th)
+		return false
+	}
+
+	// Start a goroutine that waits for the end of each interval, plus the
+	// grace period, and then emits the candles that are finished.
+	return c.spawn(func() {
+		defer finish()
+
+		for {
+			timer := time.NewTimer(time.Until(a.nextAdvance(time.Now())))
+			select {
+			case <-timer.C:
+			case <-done:
+				timer.Stop()
+				return
+			case <-stop:
+				timer.Stop()
+				return
+			}
+
+			a.Advance(time.Now())
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package bittrex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestNewCandleAggregator(t *testing.T) {
+	cases := map[string]struct {
+		intervals []time.Duration
+		exp       []time.Duration
+		wantErr   string
+	}{
+		"sorted": {
+			intervals: []time.Duration{time.Hour, time.Minute, 5 * time.Minute, time.Minute},
+			exp:       []time.Duration{time.Minute, 5 * time.Minute, time.Hour},
+		},
+		"none": {
+			wantErr: "no intervals specified",
+		},
+		"not positive": {
+			intervals: []time.Duration{0, time.Minute},
+			wantErr:   "invalid interval: 0s",
+		},
+		"not a multiple": {
+			intervals: []time.Duration{2 * time.Minute, 5 * time.Minute},
+			wantErr:   "interval 5m0s is not a multiple of 2m0s",
+		},
+	}
+
+	for id, tc := range cases {
+		a, err := bittrex.NewCandleAggregator(tc.intervals...)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+			continue
+		}
+		ok(t, id, err)
+		equals(t, id, tc.exp, a.Intervals())
+	}
+}
+
+func TestCandleAggregator(t *testing.T) {
+	a, err := bittrex.NewCandleAggregator(time.Minute, 5*time.Minute)
+	ok(t, "new", err)
+	a.GracePeriod = 0
+
+	_, err = a.Register(time.Hour, func(bittrex.Candle) {})
+	errMatches(t, "register", err, "interval 1h0m0s is not aggregated")
+
+	got := make(map[time.Duration][]bittrex.Candle)
+	for _, interval := range a.Intervals() {
+		interval := interval
+		_, err = a.Register(interval, func(c bittrex.Candle) { got[interval] = append(got[interval], c) })
+		ok(t, "register", err)
+	}
+
+	// The trades are replayed without a client.
+	start := time.Date(2018, 1, 20, 13, 55, 0, 0, time.UTC)
+	for _, tr := range []struct {
+		offset          time.Duration
+		typ             bittrex.TradeType
+		price, quantity float64
+	}{
+		{10 * time.Second, bittrex.BuyType, 1, 1},
+		{50 * time.Second, bittrex.SellType, 2, 1},
+		{90 * time.Second, bittrex.BuyType, 4, 2},
+		{250 * time.Second, bittrex.SellType, 0.5, 1},
+		{310 * time.Second, bittrex.BuyType, 3, 1},
+	} {
+		at := start.Add(tr.offset)
+		a.Advance(at)
+		equals(t, "add", true, a.Add(bittrex.Trade{
+			BaseCurrency:   "BTC",
+			MarketCurrency: "LTC",
+			Type:           tr.typ,
+			Price:          tr.price,
+			Quantity:       tr.quantity,
+			Time:           at,
+		}))
+	}
+
+	// The volumes are followed by the base volume, the VWAP and the number of
+	// trades.
+	candle := func(offset time.Duration, o, h, l, c, buy, sell, base, vwap float64, trades int) bittrex.Candle {
+		return bittrex.Candle{
+			Market:     "BTC-LTC",
+			Time:       start.Add(offset),
+			Open:       o,
+			High:       h,
+			Low:        l,
+			Close:      c,
+			Volume:     buy + sell,
+			BuyVolume:  buy,
+			SellVolume: sell,
+			BaseVolume: base,
+			VWAP:       vwap,
+			Trades:     trades,
+		}
+	}
+	equals(t, "1m", []bittrex.Candle{
+		candle(0, 1, 2, 1, 2, 1, 1, 3, 1.5, 2),
+		candle(time.Minute, 4, 4, 4, 4, 2, 0, 8, 4, 1),
+		candle(4*time.Minute, 0.5, 0.5, 0.5, 0.5, 0, 1, 0.5, 0.5, 1),
+	}, got[time.Minute])
+	equals(t, "5m", []bittrex.Candle{candle(0, 1, 4, 0.5, 0.5, 3, 2, 11.5, 2.3, 4)}, got[5*time.Minute])
+
+	// Flushing finalizes the candles that are still open.
+	a.Flush()
+	equals(t, "flushed 1m", candle(5*time.Minute, 3, 3, 3, 3, 1, 0, 3, 3, 1), got[time.Minute][3])
+	equals(t, "flushed 5m", candle(5*time.Minute, 3, 3, 3, 3, 1, 0, 3, 3, 1), got[5*time.Minute][1])
+
+	// Trades that fall in a flushed candle of any interval are dropped, so
+	// that no candle is emitted twice.
+	trade := func(offset time.Duration, price float64) bittrex.Trade {
+		return bittrex.Trade{
+			BaseCurrency:   "BTC",
+			MarketCurrency: "LTC",
+			Type:           bittrex.BuyType,
+			Price:          price,
+			Quantity:       1,
+			Time:           start.Add(offset),
+		}
+	}
+	equals(t, "flushed 5m", false, a.Add(trade(7*time.Minute, 5)))
+	equals(t, "later", true, a.Add(trade(11*time.Minute, 6)))
+	a.Flush()
+	equals(t, "1m", 5, len(got[time.Minute]))
+	equals(t, "later 1m", candle(11*time.Minute, 6, 6, 6, 6, 1, 0, 6, 6, 1), got[time.Minute][4])
+	equals(t, "5m", 3, len(got[5*time.Minute]))
+	equals(t, "later 5m", candle(10*time.Minute, 6, 6, 6, 6, 1, 0, 6, 6, 1), got[5*time.Minute][2])
+}
+
+func TestCandleAggregator_FillGaps(t *testing.T) {
+	a, err := bittrex.NewCandleAggregator(time.Minute)
+	ok(t, "new", err)
+	a.GracePeriod = 0
+	a.FillGaps = true
+
+	var got []bittrex.Candle
+	_, err = a.Register(time.Minute, func(c bittrex.Candle) { got = append(got, c) })
+	ok(t, "register", err)
+
+	start := time.Date(2018, 1, 20, 13, 55, 0, 0, time.UTC)
+	trade := func(market string, minute int, price float64) bittrex.Trade {
+		return bittrex.Trade{
+			BaseCurrency:   "BTC",
+			MarketCurrency: market,
+			Type:           bittrex.BuyType,
+			Price:          price,
+			Quantity:       1,
+			Time:           start.Add(time.Duration(minute)*time.Minute + time.Second),
+		}
+	}
+	for _, tr := range []bittrex.Trade{trade("LTC", 0, 1), trade("ETH", 1, 3), trade("LTC", 3, 2)} {
+		equals(t, "add", true, a.Add(tr))
+	}
+
+	traded := func(market string, minute int, price float64) bittrex.Candle {
+		return bittrex.Candle{
+			Market:     "BTC-" + market,
+			Time:       start.Add(time.Duration(minute) * time.Minute),
+			Open:       price,
+			High:       price,
+			Low:        price,
+			Close:      price,
+			Volume:     1,
+			BuyVolume:  1,
+			BaseVolume: price,
+			VWAP:       price,
+			Trades:     1,
+		}
+	}
+	flat := func(market string, minute int, price float64) bittrex.Candle {
+		return bittrex.Candle{
+			Market: "BTC-" + market,
+			Time:   start.Add(time.Duration(minute) * time.Minute),
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+		}
+	}
+
+	// A market only gets candles once it has had a trade.
+	a.Advance(start.Add(5 * time.Minute))
+	equals(t, "filled", []bittrex.Candle{
+		traded("LTC", 0, 1),
+		traded("ETH", 1, 3),
+		flat("LTC", 1, 1),
+		flat("ETH", 2, 3),
+		flat("LTC", 2, 1),
+		flat("ETH", 3, 3),
+		traded("LTC", 3, 2),
+		flat("ETH", 4, 3),
+		flat("LTC", 4, 2),
+	}, got)
+
+	// Quiet intervals keep producing candles.
+	got = nil
+	a.Advance(start.Add(6 * time.Minute))
+	equals(t, "quiet", []bittrex.Candle{flat("ETH", 5, 3), flat("LTC", 5, 2)}, got)
+}
@@ -0,0 +1,132 @@
+package bittrex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCandleBuckets(t *testing.T) {
+	start := time.Date(2018, 1, 20, 13, 54, 0, 0, time.UTC)
+	trade := func(market string, typ TradeType, offset time.Duration, price, quantity float64) Trade {
+		return Trade{
+			BaseCurrency:   "BTC",
+			MarketCurrency: market,
+			Type:           typ,
+			Price:          price,
+			Quantity:       quantity,
+			Time:           start.Add(offset),
+		}
+	}
+
+	cb := newCandleBuckets(time.Minute)
+	for _, tr := range []Trade{
+		trade("LTC", BuyType, 30*time.Second, 2, 1),
+		trade("LTC", SellType, 10*time.Second, 1, 1), // Arrives late, but opens the candle.
+		trade("LTC", BuyType, 50*time.Second, 3, 1),
+		trade("LTC", BuyType, 40*time.Second, 4, 2),
+		trade("ETH", BuyType, 70*time.Second, 5, 2),
+		trade("LTC", SellType, 65*time.Second, 6, 1),
+	} {
+		equals(t, "add", true, cb.add(tr))
+	}
+
+	// Nothing is finalized before the grace period is over.
+	equals(t, "grace", []Candle(nil), cb.finalize(start.Add(time.Minute+time.Second), 5*time.Second))
+	equals(t, "next", start.Add(time.Minute+5*time.Second), cb.nextFinalization(start.Add(time.Minute+time.Second), 5*time.Second))
+
+	exp := []Candle{{
+		Market:     "BTC-LTC",
+		Time:       start,
+		Open:       1,
+		High:       4,
+		Low:        1,
+		Close:      3,
+		Volume:     5,
+		BuyVolume:  4,
+		SellVolume: 1,
+		BaseVolume: 14,
+		VWAP:       2.8,
+		Trades:     4,
+	}}
+	equals(t, "first", exp, cb.finalize(start.Add(time.Minute+5*time.Second), 5*time.Second))
+
+	// Trades of a finalized bucket are dropped.
+	equals(t, "late", false, cb.add(trade("LTC", BuyType, 59*time.Second, 9, 1)))
+
+	exp = []Candle{
+		{
+			Market:     "BTC-ETH",
+			Time:       start.Add(time.Minute),
+			Open:       5,
+			High:       5,
+			Low:        5,
+			Close:      5,
+			Volume:     2,
+			BuyVolume:  2,
+			BaseVolume: 10,
+			VWAP:       5,
+			Trades:     1,
+		},
+		{
+			Market:     "BTC-LTC",
+			Time:       start.Add(time.Minute),
+			Open:       6,
+			High:       6,
+			Low:        6,
+			Close:      6,
+			Volume:     1,
+			SellVolume: 1,
+			BaseVolume: 6,
+			VWAP:       6,
+			Trades:     1,
+		},
+	}
+	equals(t, "second", exp, cb.finalize(start.Add(3*time.Minute), 5*time.Second))
+	equals(t, "empty", []Candle(nil), cb.finalize(start.Add(4*time.Minute), 5*time.Second))
+}
+
+func TestCandle_merge(t *testing.T) {
+	start := time.Date(2018, 1, 20, 13, 55, 0, 0, time.UTC)
+	candle := Candle{
+		Market:     "BTC-LTC",
+		Time:       start,
+		Open:       2,
+		High:       3,
+		Low:        2,
+		Close:      3,
+		Volume:     2,
+		BuyVolume:  2,
+		BaseVolume: 5,
+		VWAP:       2.5,
+		Trades:     2,
+	}
+
+	candle.merge(Candle{
+		Market:     "BTC-LTC",
+		Time:       start.Add(time.Minute),
+		Open:       3,
+		High:       4,
+		Low:        1,
+		Close:      1,
+		Volume:     2,
+		SellVolume: 2,
+		BaseVolume: 3,
+		VWAP:       1.5,
+		Trades:     1,
+	})
+
+	equals(t, "merged", Candle{
+		Market:     "BTC-LTC",
+		Time:       start,
+		Open:       2,
+		High:       4,
+		Low:        1,
+		Close:      1,
+		Volume:     4,
+		BuyVolume:  2,
+		SellVolume: 2,
+		BaseVolume: 8,
+		VWAP:       2,
+		Trades:     3,
+	}, candle)
+}
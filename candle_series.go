@@ -0,0 +1,266 @@
+package bittrex
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// candleSeriesInterval is the interval of the candles of a CandleSeries, which
+// is the interval of the ticks that it is backfilled from.
+const candleSeriesInterval = time.Minute
+
+// DefaultCandleSeriesPollInterval is the default PollInterval of a
+// CandleSeries.
+const DefaultCandleSeriesPollInterval = time.Minute
+
+// DefaultCandleSeriesGracePeriod is the default GracePeriod of a CandleSeries.
+const DefaultCandleSeriesGracePeriod = 5 * time.Minute
+
+// CandleSeries produces a seamless series of one minute candles for a market.
+// It backfills the history from Ticks and then switches to the candles that
+// are built from the live trades, once the ticks have caught up with them.
+// The ticks make up the minutes before the live trades started to be
+// watched, and only once those minutes are over, since the latest tick is
+// still in progress. The live trades make up all of the later minutes. Since
+// there are no ticks for the minutes without trades, the ticks are only
+// waited for until GracePeriod after the live candles start.
+//
+// The series has no gaps: a minute without any trades gets a candle whose
+// prices are the close of the previous one and whose volume is zero. Such a
+// candle is emitted once the next candle with trades is.
+type CandleSeries struct {
+	Market string
+
+	// PollInterval is how often the ticks are fetched until they overlap with
+	// the live candles.
+	PollInterval time.Duration
+
+	// GracePeriod is how long after the live candles start the ticks are
+	// waited for. Ticks are only made for the minutes with trades, so once it
+	// has passed, the minutes that the ticks are still missing are taken to
+	// have had none.
+	GracePeriod time.Duration
+
+	// RetryWaitDuration is how long to wait before fetching the ticks again
+	// after a failure. The wait doubles with each failure in a row, up to
+	// PollInterval.
+	RetryWaitDuration time.Duration
+
+	client *Client
+
+	// fetch returns the candles of the ticks, in order, and now returns the
+	// current time. They are replaced by the tests.
+	fetch func(ctx context.Context) ([]Candle, error)
+	now   func() time.Time
+
+	// live holds the live candles that have yet to be handled. Ready is
+	// notified when one is added.
+	live      []Candle
+	liveReady chan struct{}
+	liveMux   sync.Mutex
+}
+
+// NewCandleSeries creates a candle series for the market, which is fed by the
+// client.
+func NewCandleSeries(c *Client, market string) *CandleSeries {
+	s := &CandleSeries{
+		Market:            market,
+		PollInterval:      DefaultCandleSeriesPollInterval,
+		GracePeriod:       DefaultCandleSeriesGracePeriod,
+		RetryWaitDuration: c.RetryWaitDuration,
+		client:            c,
+		now:               time.Now,
+		liveReady:         make(chan struct{}, 1),
+	}
+
+	s.fetch = func(ctx context.Context) ([]Candle, error) {
+		ticks, err := c.TicksContext(ctx, market)
+		if err != nil {
+			return nil, err
+		}
+		return tickCandles(market, ticks)
+	}
+
+	return s
+}
+
+// Run runs the handler against each candle of the series, in order, until the
+// context is done or the client is closed. The market has to be subscribed to
+// separately. Run returns the reason that it stopped.
+func (s *CandleSeries) Run(ctx context.Context, h CandleHandler) error {
+	c := s.client
+
+	err := c.streamReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	a, err := NewCandleAggregator(candleSeriesInterval)
+	if err != nil {
+		return err
+	}
+	a.GracePeriod = c.CandleGracePeriod
+	_, err = a.Register(candleSeriesInterval, s.addLive)
+	if err != nil {
+		return err
+	}
+
+	// The first minute is only partly covered by the live trades, so the live
+	// candles are only complete from the next one onwards.
+	liveStart := s.now().UTC().Truncate(candleSeriesInterval).Add(candleSeriesInterval)
+
+	err = c.AggregateCandles(ctx, a, s.Market)
+	if err != nil {
+		return err
+	}
+
+	// Stop once the client is closed, too.
+	err = c.runStream(ctx, make(chan struct{}), cancel)
+	if err != nil {
+		return err
+	}
+
+	err = s.stitch(ctx, liveStart, h)
+	if c.isClosed() {
+		return errors.New("client is closed")
+	}
+	return err
+}
+
+// addLive adds a live candle to the series.
+func (s *CandleSeries) addLive(candle Candle) {
+	s.liveMux.Lock()
+	s.live = append(s.live, candle)
+	s.liveMux.Unlock()
+
+	select {
+	case s.liveReady <- struct{}{}:
+	default:
+	}
+}
+
+// takeLive returns the live candles that have yet to be handled.
+func (s *CandleSeries) takeLive() []Candle {
+	s.liveMux.Lock()
+	defer s.liveMux.Unlock()
+
+	live := s.live
+	s.live = nil
+	return live
+}
+
+// stitch runs the handler against the candles of the ticks that come before
+// liveStart, and against the live candles that follow them from then on.
+func (s *CandleSeries) stitch(ctx context.Context, liveStart time.Time, h CandleHandler) error {
+	var last *Candle
+	emit := func(candle Candle) {
+		if last != nil && !candle.Time.After(last.Time) {
+			// The minute is covered already.
+			return
+		}
+
+		if last != nil {
+			for at := last.Time.Add(candleSeriesInterval); at.Before(candle.Time); at = at.Add(candleSeriesInterval) {
+				h(Candle{
+					Market: s.Market,
+					Time:   at,
+					Open:   last.Close,
+					High:   last.Close,
+					Low:    last.Close,
+					Close:  last.Close,
+				})
+			}
+		}
+
+		h(candle)
+		last = &candle
+	}
+
+	// Backfill from the ticks until they have caught up with the minute
+	// before liveStart and that minute is over, or until the grace period has
+	// passed without them doing so. Failed fetches are retried.
+	failures := 0
+	for {
+		fetched := s.now()
+		candles, err := s.fetch(ctx)
+
+		wait := s.PollInterval
+		if err != nil {
+			failures++
+			s.reportError(errors.Wrap(err, "failed to backfill the candles"))
+			if w := backoff(s.RetryWaitDuration, failures); w < wait {
+				wait = w
+			}
+		} else {
+			failures = 0
+			caughtUp := false
+			for _, candle := range candles {
+				if !candle.Time.Before(liveStart.Add(-candleSeriesInterval)) {
+					caughtUp = true
+				}
+
+				// Only the minutes that were over when the ticks were
+				// fetched are final.
+				if candle.Time.Before(liveStart) && !candle.Time.Add(candleSeriesInterval).After(fetched) {
+					emit(candle)
+				}
+			}
+
+			// The gaps are filled in once the live candles follow.
+			if caughtUp && !fetched.Before(liveStart) || !fetched.Before(liveStart.Add(s.GracePeriod)) {
+				break
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "backfill stopped")
+		}
+	}
+
+	// Carry on with the live candles. The ones that overlap with the ticks
+	// are dropped.
+	for {
+		for _, candle := range s.takeLive() {
+			emit(candle)
+		}
+
+		select {
+		case <-s.liveReady:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "candle series stopped")
+		}
+	}
+}
+
+// reportError hands the error to the error handler of the client, if there is
+// one.
+func (s *CandleSeries) reportError(err error) {
+	if s.client != nil {
+		s.client.reportError(err)
+	}
+}
+
+// tickCandles converts the ticks of the market into candles, ordered by time.
+func tickCandles(market string, ticks []Tick) ([]Candle, error) {
+	candles := make([]Candle, 0, len(ticks))
+	for _, t := range ticks {
+		candle, err := t.Candle(market)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+	}
+
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Time.Before(candles[j].Time) })
+
+	return candles, nil
+}
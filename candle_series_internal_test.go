@@ -0,0 +1,176 @@
+package bittrex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCandleSeries_stitch(t *testing.T) {
+	start := time.Date(2018, 2, 12, 7, 10, 0, 0, time.UTC)
+	candle := func(minute int, price, volume float64) Candle {
+		return Candle{
+			Market: "BTC-LTC",
+			Time:   start.Add(time.Duration(minute) * time.Minute),
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Volume: volume,
+		}
+	}
+
+	// The first fetch fails and is retried. The second is made before the
+	// live candles start, and the third one is made after. The latest tick is
+	// still in progress each time, so minute 3 is only taken from the third
+	// fetch, and the partial minute 4 is left to the live candles.
+	type fetch struct {
+		at      time.Time
+		candles []Candle
+		err     error
+	}
+	fetches := []fetch{
+		{at: start.Add(3 * time.Minute), err: errors.New("unavailable")},
+		{at: start.Add(3*time.Minute + 30*time.Second), candles: []Candle{
+			candle(0, 1, 1), candle(2, 2, 1), candle(3, 2, 1),
+		}},
+		{at: start.Add(4*time.Minute + 10*time.Second), candles: []Candle{
+			candle(0, 1, 1), candle(2, 2, 1), candle(3, 3, 1), candle(4, 8, 1),
+		}},
+	}
+	s := &CandleSeries{
+		Market:            "BTC-LTC",
+		PollInterval:      time.Millisecond,
+		GracePeriod:       time.Minute,
+		RetryWaitDuration: time.Millisecond,
+		liveReady:         make(chan struct{}, 1),
+		now:               func() time.Time { return fetches[0].at },
+		fetch: func(context.Context) ([]Candle, error) {
+			f := fetches[0]
+			if len(fetches) > 1 {
+				fetches = fetches[1:]
+			}
+			return f.candles, f.err
+		},
+	}
+
+	// The live candle of minute 3 is superseded by its tick.
+	s.addLive(candle(3, 9, 9))
+	s.addLive(candle(4, 4, 1))
+	s.addLive(candle(5, 5, 1))
+
+	got := make(chan Candle, 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- s.stitch(ctx, start.Add(4*time.Minute), func(c Candle) { got <- c }) }()
+
+	receive := func(id string) Candle {
+		select {
+		case c := <-got:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for the candle", id)
+			return Candle{}
+		}
+	}
+
+	for i, exp := range []Candle{
+		candle(0, 1, 1),
+		candle(1, 1, 0), // Filled in.
+		candle(2, 2, 1),
+		candle(3, 3, 1),
+		candle(4, 4, 1),
+		candle(5, 5, 1),
+	} {
+		equals(t, fmt.Sprintf("candle %d", i), exp, receive("backfill"))
+	}
+
+	// Live candles keep coming once the series has switched over.
+	s.addLive(candle(7, 7, 1))
+	equals(t, "filled", candle(6, 5, 0), receive("live"))
+	equals(t, "live", candle(7, 7, 1), receive("live"))
+
+	cancel()
+	select {
+	case err := <-errs:
+		errMatches(t, "stopped", err, "candle series stopped: context canceled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the series to stop")
+	}
+}
+
+func TestCandleSeries_stitch_Quiet(t *testing.T) {
+	start := time.Date(2018, 2, 12, 7, 10, 0, 0, time.UTC)
+	candle := func(minute int, price, volume float64) Candle {
+		return Candle{
+			Market: "BTC-LTC",
+			Time:   start.Add(time.Duration(minute) * time.Minute),
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Volume: volume,
+		}
+	}
+
+	// The market has had no trades since minute 0, so the ticks never catch
+	// up. The backfill ends once the grace period has passed.
+	fetches := []time.Time{
+		start.Add(4*time.Minute + 30*time.Second),
+		start.Add(5 * time.Minute),
+	}
+	s := &CandleSeries{
+		Market:       "BTC-LTC",
+		PollInterval: time.Millisecond,
+		GracePeriod:  time.Minute,
+		liveReady:    make(chan struct{}, 1),
+		now:          func() time.Time { return fetches[0] },
+		fetch: func(context.Context) ([]Candle, error) {
+			if len(fetches) > 1 {
+				fetches = fetches[1:]
+			}
+			return []Candle{candle(0, 1, 1)}, nil
+		},
+	}
+
+	got := make(chan Candle, 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- s.stitch(ctx, start.Add(4*time.Minute), func(c Candle) { got <- c }) }()
+
+	receive := func(id string) Candle {
+		select {
+		case c := <-got:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for the candle", id)
+			return Candle{}
+		}
+	}
+	equals(t, "backfill", candle(0, 1, 1), receive("backfill"))
+
+	// The minutes up to the first live candle are filled in.
+	s.addLive(candle(6, 6, 1))
+	for i, exp := range []Candle{
+		candle(1, 1, 0),
+		candle(2, 1, 0),
+		candle(3, 1, 0),
+		candle(4, 1, 0),
+		candle(5, 1, 0),
+		candle(6, 6, 1),
+	} {
+		equals(t, fmt.Sprintf("candle %d", i), exp, receive("live"))
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		errMatches(t, "stopped", err, "candle series stopped: context canceled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the series to stop")
+	}
+}
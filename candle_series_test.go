@@ -0,0 +1,65 @@
+package bittrex_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestCandleSeries_Run(t *testing.T) {
+	ts, _ := bittrex.NewMockRestServer()
+	ts.Start()
+	defer ts.Close()
+
+	ss := bittrex.NewMockSignalRServer(nil)
+	defer ss.Close()
+
+	c := bittrex.New("", "")
+	c.HTTPClient = ts.Client()
+	c.HostAddr = ts.URL
+	ss.ConfigureClient(c)
+
+	ticks, err := c.Ticks("BTC-WAVES")
+	ok(t, "ticks", err)
+	first, err := ticks[0].Time()
+	ok(t, "ticks", err)
+
+	candles := make(chan bittrex.Candle, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	s := bittrex.NewCandleSeries(c, "BTC-WAVES")
+	go func() {
+		errs <- s.Run(ctx, func(candle bittrex.Candle) {
+			select {
+			case candles <- candle:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	// The series is backfilled from the ticks, one minute after the other.
+	for i := 0; i < 5; i++ {
+		select {
+		case candle := <-candles:
+			equals(t, "market", "BTC-WAVES", candle.Market)
+			equals(t, "time", first.Add(time.Duration(i)*time.Minute), candle.Time)
+			if i == 0 {
+				equals(t, "open", ticks[0].Open, candle.Open)
+				equals(t, "close", ticks[0].Close, candle.Close)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the candle")
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		errMatches(t, "stopped", err, "context canceled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the series to stop")
+	}
+}
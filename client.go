@@ -4,8 +4,10 @@ package bittrex
 //go:generate go fmt ./internal/bindata.go
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,13 +30,38 @@ type Client struct {
 	HTTPTransport *http.Transport
 
 	// MaxRetries indicates the maximum number of times to attempt an HTTP
-	// operation before failing.
+	// operation before failing. Transport errors, 5xx and 429 responses, and
+	// Cloudflare challenge pages are retried. Orders are only retried when it
+	// is known that the failed attempt did not place the order.
 	MaxRetries int
 
 	// RetryWaitDuration is used to determine how long to wait between retries
-	// of various operations.
+	// of various operations. The wait doubles after each attempt and is
+	// randomized by up to half of its length.
 	RetryWaitDuration time.Duration
 
+	// PublicRateLimiter and PrivateRateLimiter are optional. They limit how
+	// often requests are sent to public and authenticated endpoints
+	// respectively. Subscriptions sent over the websocket connection count
+	// against the public budget.
+	PublicRateLimiter  *RateLimiter
+	PrivateRateLimiter *RateLimiter
+
+	// WithdrawalPolicy restricts the withdrawals made by Withdraw. If it is
+	// nil, all withdrawals are refused.
+	WithdrawalPolicy *WithdrawalPolicy
+
+	// InvocationTimeout is how long the result of a hub invocation, such as a
+	// subscription, is waited for. If it is zero, only the context of the
+	// call limits the wait.
+	InvocationTimeout time.Duration
+
+	// MessageBufferSize is how many SignalR messages with market or account
+	// data can wait to be processed. Once the buffer is full, the connection
+	// isn't read from until there is room again, which holds up the responses
+	// to hub invocations as well.
+	MessageBufferSize int
+
 	// A Bittrex-supplied API key and secret.
 	APIKey    string
 	APISecret string
@@ -46,23 +73,142 @@ type Client struct {
 	// message.
 	currentMsgID int
 
+	// invocations holds the channels that wait for the responses to hub
+	// invocations, keyed by message ID. The mutex also protects currentMsgID.
+	invocations    map[string]chan signalr.Message
+	invocationsMux sync.Mutex
+
+	// TrackOrderBooks indicates that a local order book is maintained for
+	// each market that is subscribed to. See LiveOrderBook.
+	TrackOrderBooks bool
+
+	// NonceGapTimeout is how long a missing exchange update is waited for
+	// before the order book of its market is considered stale and is
+	// resynced, and before the ordered trade handlers move on past it, which
+	// is reported as a NonceGap stream event. Until then, the updates that
+	// follow it are buffered.
+	NonceGapTimeout time.Duration
+
+	// books holds the local order books, keyed by market.
+	books    map[string]*liveOrderBook
+	booksMux sync.Mutex
+
+	// bookHandlers holds all of the registered order book handler functions.
+	bookHandlers    []*bookHandler
+	bookHandlersMux sync.Mutex
+
+	// streamEventHandlers holds all of the registered stream event handler
+	// functions.
+	streamEventHandlers    []*streamEventHandler
+	streamEventHandlersMux sync.Mutex
+
 	// Started indicates if the underlying SignalR client has been started.
+	// Start holds the attempt to start it that is currently in progress.
 	started    bool
+	start      *startAttempt
 	startedMux sync.Mutex
 
+	// errHandler is the error handler that the SignalR client was started
+	// with. It is protected by startedMux.
+	errHandler ErrHandler
+
+	// done is closed by Close to stop the goroutines started by the Client,
+	// which are tracked by wg. Closed indicates that Close has been called.
+	// They are protected by lifeMux.
+	done    chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	lifeMux sync.Mutex
+
+	// conn is the last connection of the underlying SignalR client that
+	// watchConnection has seen. Close closes it to stop the client.
+	conn    signalr.WebsocketConn
+	connMux sync.Mutex
+
+	// subscriptions holds the markets that are subscribed to, subscribing
+	// holds the ones whose subscription is still being sent, and
+	// summarySubscriptions and summarySubscribing do the same for the hub
+	// methods of the summary subscriptions. Authenticated indicates that the
+	// connection is meant to be authenticated. They are protected by
+	// subscriptionsMux.
+	subscriptions        map[string]bool
+	subscribing          map[string]*subscriptionAttempt
+	summarySubscriptions map[string]bool
+	summarySubscribing   map[string]*subscriptionAttempt
+	authenticated        bool
+	subscriptionsMux     sync.Mutex
+
+	// summaries holds the latest summary of each market and summaryNonces
+	// holds the nonce of the message that its latest summary of each hub
+	// method came from.
+	summaries     map[string]MarketSummary
+	summaryNonces map[summaryNonceKey]uint
+	summariesMux  sync.Mutex
+
+	// summaryHandlers holds all of the registered market summary handler
+	// functions.
+	summaryHandlers    []*summaryHandler
+	summaryHandlersMux sync.Mutex
+
+	// accountHandlers holds all of the registered order and balance delta
+	// handler functions.
+	accountHandlers    []*accountHandler
+	accountHandlersMux sync.Mutex
+
 	// tradeHandlers holds all of the registered trade handler functions.
-	tradeHandlers    []TradeHandler
+	// tradeHandlerSeq is the ID of the next one to be registered.
+	tradeHandlers    []*tradeHandler
+	tradeHandlerSeq  int
 	tradeHandlersMux sync.Mutex
 
+	// OrderedDelivery makes every trade handler see the trades of each market
+	// in the order in which they happened. Each handler then gets a queue per
+	// market that holds up to QueueSize trades, and QueueOverflow decides what
+	// happens when a queue is full. Otherwise, each trade is handed to each
+	// handler in a goroutine of its own. Set these before subscribing.
+	OrderedDelivery bool
+	QueueSize       int
+	QueueOverflow   OverflowPolicy
+
+	// CandleGracePeriod is how long a candle waits for late trades after its
+	// interval ends, before it is finalized and emitted.
+	CandleGracePeriod time.Duration
+
+	// sequences holds the trade sequence of each market, which puts the
+	// trades back in order for the ordered handlers. The batches that the
+	// sequences release are dispatched under dispatchMux, which is taken
+	// before sequencesMux.
+	sequences    map[string]*tradeSequence
+	sequencesMux sync.Mutex
+	dispatchMux  sync.Mutex
+
 	// HostAddr address is the address of the Bittrex server providing the service
 	// we're using. Using this variable allows us to more simply test
 	// functionality in this package.
 	HostAddr string
 }
 
+// prepareRestCall creates a REST call that uses the settings of this client and
+// is bound to the specified context.
+func (c *Client) prepareRestCall(ctx context.Context) *restCall {
+	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
+	rc.ctx = ctx
+	rc.maxRetries = c.MaxRetries
+	rc.retryWaitDuration = c.RetryWaitDuration
+	rc.publicLimiter = c.PublicRateLimiter
+	rc.privateLimiter = c.PrivateRateLimiter
+	return rc
+}
+
 // Markets gets the markets that are traded on Bittrex.
 func (c *Client) Markets() ([]Market, error) {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
+	return c.MarketsContext(context.Background())
+}
+
+// MarketsContext is like Markets, but the call is bound to the specified
+// context.
+func (c *Client) MarketsContext(ctx context.Context) ([]Market, error) {
+	rc := c.prepareRestCall(ctx)
 	err := rc.doV1_1("public/getmarkets", false)
 	if err != nil {
 		err = errors.Wrap(err, "public/getmarkets failed")
@@ -70,7 +216,7 @@ func (c *Client) Markets() ([]Market, error) {
 	}
 
 	var ms []Market
-	err = json.Unmarshal(*rc.res.Result, &ms)
+	err = rc.decodeResult(&ms)
 	if err != nil {
 		return []Market{}, errors.Wrap(err, "json unmarshal failed")
 	}
@@ -78,16 +224,195 @@ func (c *Client) Markets() ([]Market, error) {
 	return ms, nil
 }
 
+// Ticker gets the current bid, ask, and last trade price of a market.
+func (c *Client) Ticker(market string) (Ticker, error) {
+	return c.TickerContext(context.Background(), market)
+}
+
+// TickerContext is like Ticker, but the call is bound to the specified
+// context.
+func (c *Client) TickerContext(ctx context.Context, market string) (Ticker, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"market": market,
+	}
+
+	err := rc.doV1_1("public/getticker", false)
+	if err != nil {
+		return Ticker{}, errors.Wrap(err, "public/getticker failed")
+	}
+
+	var t Ticker
+	err = rc.decodeResult(&t)
+	if err != nil {
+		return Ticker{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return t, nil
+}
+
+// MarketSummaries gets the last 24 hours of trading activity for all markets.
+func (c *Client) MarketSummaries() ([]MarketSummary, error) {
+	return c.MarketSummariesContext(context.Background())
+}
+
+// MarketSummariesContext is like MarketSummaries, but the call is bound to the
+// specified context.
+func (c *Client) MarketSummariesContext(ctx context.Context) ([]MarketSummary, error) {
+	rc := c.prepareRestCall(ctx)
+	err := rc.doV1_1("public/getmarketsummaries", false)
+	if err != nil {
+		return []MarketSummary{}, errors.Wrap(err, "public/getmarketsummaries failed")
+	}
+
+	var ss []MarketSummary
+	err = rc.decodeResult(&ss)
+	if err != nil {
+		return []MarketSummary{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return ss, nil
+}
+
+// MarketSummary gets the last 24 hours of trading activity for a market.
+func (c *Client) MarketSummary(market string) (MarketSummary, error) {
+	return c.MarketSummaryContext(context.Background(), market)
+}
+
+// MarketSummaryContext is like MarketSummary, but the call is bound to the
+// specified context.
+func (c *Client) MarketSummaryContext(ctx context.Context, market string) (MarketSummary, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"market": market,
+	}
+
+	err := rc.doV1_1("public/getmarketsummary", false)
+	if err != nil {
+		return MarketSummary{}, errors.Wrap(err, "public/getmarketsummary failed")
+	}
+
+	// The summary is returned as the only element of a list.
+	var ss []MarketSummary
+	err = rc.decodeResult(&ss)
+	if err != nil {
+		return MarketSummary{}, errors.Wrap(err, "json unmarshal failed")
+	}
+	if len(ss) != 1 {
+		return MarketSummary{}, errors.Errorf("expected 1 market summary, got %d", len(ss))
+	}
+
+	return ss[0], nil
+}
+
+// OrderBook gets the buy orders, the sell orders, or both for a market. Depth
+// limits the number of entries returned per side; Bittrex uses its default if
+// depth is not positive.
+func (c *Client) OrderBook(market string, side OrderBookSide, depth int) (OrderBook, error) {
+	return c.OrderBookContext(context.Background(), market, side, depth)
+}
+
+// OrderBookContext is like OrderBook, but the call is bound to the specified
+// context.
+func (c *Client) OrderBookContext(ctx context.Context, market string, side OrderBookSide, depth int) (OrderBook, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"market": market,
+		"type":   string(side),
+	}
+	if depth > 0 {
+		rc.params["depth"] = strconv.Itoa(depth)
+	}
+
+	err := rc.doV1_1("public/getorderbook", false)
+	if err != nil {
+		return OrderBook{}, errors.Wrap(err, "public/getorderbook failed")
+	}
+
+	// Both sides are returned as an object, whereas a single side is
+	// returned as a list.
+	book := OrderBook{Market: market}
+	switch side {
+	case BuySide:
+		err = rc.decodeResult(&book.Buy)
+	case SellSide:
+		err = rc.decodeResult(&book.Sell)
+	default:
+		err = rc.decodeResult(&book)
+	}
+	if err != nil {
+		return OrderBook{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return book, nil
+}
+
+// MarketHistory gets the latest trades that were filled in a market.
+func (c *Client) MarketHistory(market string) ([]MarketTrade, error) {
+	return c.MarketHistoryContext(context.Background(), market)
+}
+
+// MarketHistoryContext is like MarketHistory, but the call is bound to the
+// specified context.
+func (c *Client) MarketHistoryContext(ctx context.Context, market string) ([]MarketTrade, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"market": market,
+	}
+
+	err := rc.doV1_1("public/getmarkethistory", false)
+	if err != nil {
+		return []MarketTrade{}, errors.Wrap(err, "public/getmarkethistory failed")
+	}
+
+	var ts []MarketTrade
+	err = rc.decodeResult(&ts)
+	if err != nil {
+		return []MarketTrade{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return ts, nil
+}
+
+// Currencies gets the currencies that are supported by Bittrex.
+func (c *Client) Currencies() ([]Currency, error) {
+	return c.CurrenciesContext(context.Background())
+}
+
+// CurrenciesContext is like Currencies, but the call is bound to the specified
+// context.
+func (c *Client) CurrenciesContext(ctx context.Context) ([]Currency, error) {
+	rc := c.prepareRestCall(ctx)
+	err := rc.doV1_1("public/getcurrencies", false)
+	if err != nil {
+		return []Currency{}, errors.Wrap(err, "public/getcurrencies failed")
+	}
+
+	var cs []Currency
+	err = rc.decodeResult(&cs)
+	if err != nil {
+		return []Currency{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return cs, nil
+}
+
 // Balances gets the balances held for all currencies in the account.
 func (c *Client) Balances() ([]Balance, error) {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
+	return c.BalancesContext(context.Background())
+}
+
+// BalancesContext is like Balances, but the call is bound to the specified
+// context.
+func (c *Client) BalancesContext(ctx context.Context) ([]Balance, error) {
+	rc := c.prepareRestCall(ctx)
 	err := rc.doV1_1("account/getbalances", true)
 	if err != nil {
 		return []Balance{}, errors.Wrap(err, "account/getbalances failed")
 	}
 
 	var bs []Balance
-	err = json.Unmarshal(*rc.res.Result, &bs)
+	err = rc.decodeResult(&bs)
 	if err != nil {
 		return []Balance{}, errors.Wrap(err, "json unmarshal failed")
 	}
@@ -95,17 +420,141 @@ func (c *Client) Balances() ([]Balance, error) {
 	return bs, nil
 }
 
+// Balance gets the balance held for a single currency in the account.
+func (c *Client) Balance(currency string) (Balance, error) {
+	return c.BalanceContext(context.Background(), currency)
+}
+
+// BalanceContext is like Balance, but the call is bound to the specified
+// context.
+func (c *Client) BalanceContext(ctx context.Context, currency string) (Balance, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"currency": currency,
+	}
+
+	err := rc.doV1_1("account/getbalance", true)
+	if err != nil {
+		return Balance{}, errors.Wrap(err, "account/getbalance failed")
+	}
+
+	var b Balance
+	err = rc.decodeResult(&b)
+	if err != nil {
+		return Balance{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return b, nil
+}
+
+// DepositAddress gets the address to which the specified currency can be
+// deposited. If Bittrex is still generating the address, the returned error
+// satisfies IsAPIError(err, MessageAddressGenerating) and the call should be
+// repeated later.
+func (c *Client) DepositAddress(currency string) (DepositAddress, error) {
+	return c.DepositAddressContext(context.Background(), currency)
+}
+
+// DepositAddressContext is like DepositAddress, but the call is bound to the
+// specified context.
+func (c *Client) DepositAddressContext(ctx context.Context, currency string) (DepositAddress, error) {
+	rc := c.prepareRestCall(ctx)
+	rc.params = map[string]string{
+		"currency": currency,
+	}
+
+	err := rc.doV1_1("account/getdepositaddress", true)
+	if err != nil {
+		return DepositAddress{}, errors.Wrap(err, "account/getdepositaddress failed")
+	}
+
+	var a DepositAddress
+	err = rc.decodeResult(&a)
+	if err != nil {
+		return DepositAddress{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return a, nil
+}
+
+// DepositHistory gets the deposits made into the account for the specified
+// currency. If currency is empty, the deposits of all currencies are returned.
+func (c *Client) DepositHistory(currency string) ([]Deposit, error) {
+	return c.DepositHistoryContext(context.Background(), currency)
+}
+
+// DepositHistoryContext is like DepositHistory, but the call is bound to the
+// specified context.
+func (c *Client) DepositHistoryContext(ctx context.Context, currency string) ([]Deposit, error) {
+	rc := c.prepareRestCall(ctx)
+	if currency != "" {
+		rc.params = map[string]string{
+			"currency": currency,
+		}
+	}
+
+	err := rc.doV1_1("account/getdeposithistory", true)
+	if err != nil {
+		return []Deposit{}, errors.Wrap(err, "account/getdeposithistory failed")
+	}
+
+	var ds []Deposit
+	err = rc.decodeResult(&ds)
+	if err != nil {
+		return []Deposit{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return ds, nil
+}
+
+// WithdrawalHistory gets the withdrawals made from the account for the
+// specified currency. If currency is empty, the withdrawals of all currencies
+// are returned.
+func (c *Client) WithdrawalHistory(currency string) ([]Withdrawal, error) {
+	return c.WithdrawalHistoryContext(context.Background(), currency)
+}
+
+// WithdrawalHistoryContext is like WithdrawalHistory, but the call is bound to
+// the specified context.
+func (c *Client) WithdrawalHistoryContext(ctx context.Context, currency string) ([]Withdrawal, error) {
+	rc := c.prepareRestCall(ctx)
+	if currency != "" {
+		rc.params = map[string]string{
+			"currency": currency,
+		}
+	}
+
+	err := rc.doV1_1("account/getwithdrawalhistory", true)
+	if err != nil {
+		return []Withdrawal{}, errors.Wrap(err, "account/getwithdrawalhistory failed")
+	}
+
+	var ws []Withdrawal
+	err = rc.decodeResult(&ws)
+	if err != nil {
+		return []Withdrawal{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return ws, nil
+}
+
 // OrderHistory gets the latest orders made through Bittrex for the user's
 // account.
 func (c *Client) OrderHistory() ([]Order, error) {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
+	return c.OrderHistoryContext(context.Background())
+}
+
+// OrderHistoryContext is like OrderHistory, but the call is bound to the
+// specified context.
+func (c *Client) OrderHistoryContext(ctx context.Context) ([]Order, error) {
+	rc := c.prepareRestCall(ctx)
 	err := rc.doV1_1("account/getorderhistory", true)
 	if err != nil {
 		return []Order{}, errors.Wrap(err, "account/getorderhistory failed")
 	}
 
 	var orders []Order
-	err = json.Unmarshal(*rc.res.Result, &orders)
+	err = rc.decodeResult(&orders)
 	if err != nil {
 		return []Order{}, errors.Wrap(err, "json unmarshal failed")
 	}
@@ -113,10 +562,45 @@ func (c *Client) OrderHistory() ([]Order, error) {
 	return orders, nil
 }
 
-// LimitSell sends a request to create a limit sell order.
-func (c *Client) LimitSell(market string, quantity, rate float64) error {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
-	api := "market/selllimit"
+// LimitSell sends a request to create a limit sell order. The returned order
+// holds the UUID that Bittrex assigned to it.
+func (c *Client) LimitSell(market string, quantity, rate float64) (PlacedOrder, error) {
+	return c.LimitSellContext(context.Background(), market, quantity, rate)
+}
+
+// LimitSellContext is like LimitSell, but the call is bound to the specified
+// context.
+func (c *Client) LimitSellContext(ctx context.Context, market string, quantity, rate float64) (PlacedOrder, error) {
+	o, err := c.limitOrder(ctx, "market/selllimit", SellType, market, quantity, rate)
+	if err != nil {
+		return PlacedOrder{}, errors.Wrap(err, "market/selllimit failed")
+	}
+
+	return o, nil
+}
+
+// LimitBuy sends a request to create a limit buy order. The returned order
+// holds the UUID that Bittrex assigned to it.
+func (c *Client) LimitBuy(market string, quantity, rate float64) (PlacedOrder, error) {
+	return c.LimitBuyContext(context.Background(), market, quantity, rate)
+}
+
+// LimitBuyContext is like LimitBuy, but the call is bound to the specified
+// context.
+func (c *Client) LimitBuyContext(ctx context.Context, market string, quantity, rate float64) (PlacedOrder, error) {
+	o, err := c.limitOrder(ctx, "market/buylimit", BuyType, market, quantity, rate)
+	if err != nil {
+		return PlacedOrder{}, errors.Wrap(err, "market/buylimit failed")
+	}
+
+	return o, nil
+}
+
+// limitOrder places a limit order using the specified API. Since placing an
+// order is not idempotent, a failed attempt is only retried if the order can't
+// be found among the open and recently closed orders afterwards.
+func (c *Client) limitOrder(ctx context.Context, api string, side TradeType, market string, quantity, rate float64) (PlacedOrder, error) {
+	rc := c.prepareRestCall(ctx)
 
 	// Prepare the parameters.
 	rc.params = map[string]string{
@@ -125,43 +609,145 @@ func (c *Client) LimitSell(market string, quantity, rate float64) error {
 		"rate":     strconv.FormatFloat(rate, 'f', 8, 64),
 	}
 
-	// If the result is successful, no error will be thrown. The only bit of
-	// information returned is the uuid of the sell order, which we don't care
-	// about. Therefore we ignore the response.
+	orderType := "LIMIT_" + side.String()
+	since := time.Now().UTC()
+	rc.mutating = true
+	rc.notApplied = func() (bool, error) {
+		return c.orderNotPlaced(ctx, market, orderType, quantity, rate, since)
+	}
+
 	err := rc.doV1_1(api, true)
 	if err != nil {
-		return errors.Wrap(err, "market/selllimit failed")
+		return PlacedOrder{}, err
 	}
 
-	return nil
+	// The only bit of information returned is the uuid of the order.
+	var result struct {
+		UUID OrderID `json:"uuid"`
+	}
+	err = rc.decodeResult(&result)
+	if err != nil {
+		return PlacedOrder{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return PlacedOrder{
+		ID:       result.UUID,
+		Market:   market,
+		Type:     side,
+		Quantity: quantity,
+		Rate:     rate,
+	}, nil
 }
 
-// LimitBuy sends a request to create a limit buy order.
-func (c *Client) LimitBuy(market string, quantity, rate float64) error {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
-	api := "market/buylimit"
+// orderNotPlaced reports whether no order matching the specified parameters
+// exists among the open orders or the orders closed since the specified time.
+func (c *Client) orderNotPlaced(ctx context.Context, market, orderType string, quantity, rate float64, since time.Time) (bool, error) {
+	open, err := c.OpenOrdersContext(ctx, market)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get open orders")
+	}
 
-	// Prepare the parameters.
+	for _, o := range open {
+		if sameOrder(o.Exchange, o.OrderType, o.Quantity, o.Limit, market, orderType, quantity, rate) {
+			return false, nil
+		}
+	}
+
+	closed, err := c.OrderHistoryContext(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get order history")
+	}
+
+	for _, o := range closed {
+		if !sameOrder(o.Exchange, o.OrderType, o.Quantity, o.Limit, market, orderType, quantity, rate) {
+			continue
+		}
+
+		// Allow for a minute of clock skew between us and Bittrex. Orders
+		// with unparseable timestamps are assumed to match.
+		t, terr := o.Time()
+		if terr != nil || !t.Before(since.Add(-1*time.Minute)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// sameOrder indicates if an order with the specified market, type, quantity,
+// and limit has the parameters that were wanted.
+func sameOrder(market, orderType string, quantity, limit float64, wantMarket, wantType string, wantQuantity, wantRate float64) bool {
+	return market == wantMarket &&
+		orderType == wantType &&
+		strconv.FormatFloat(quantity, 'f', 8, 64) == strconv.FormatFloat(wantQuantity, 'f', 8, 64) &&
+		strconv.FormatFloat(limit, 'f', 8, 64) == strconv.FormatFloat(wantRate, 'f', 8, 64)
+}
+
+// OpenOrders gets the orders that are currently open in the specified market.
+// If market is empty, the open orders in all markets are returned.
+func (c *Client) OpenOrders(market string) ([]OpenOrder, error) {
+	return c.OpenOrdersContext(context.Background(), market)
+}
+
+// OpenOrdersContext is like OpenOrders, but the call is bound to the specified
+// context.
+func (c *Client) OpenOrdersContext(ctx context.Context, market string) ([]OpenOrder, error) {
+	rc := c.prepareRestCall(ctx)
+	if market != "" {
+		rc.params = map[string]string{
+			"market": market,
+		}
+	}
+
+	err := rc.doV1_1("market/getopenorders", true)
+	if err != nil {
+		return []OpenOrder{}, errors.Wrap(err, "market/getopenorders failed")
+	}
+
+	var orders []OpenOrder
+	err = rc.decodeResult(&orders)
+	if err != nil {
+		return []OpenOrder{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return orders, nil
+}
+
+// Order gets a single order from the user's account, whether it is open or
+// closed.
+func (c *Client) Order(id OrderID) (OrderDetail, error) {
+	return c.OrderContext(context.Background(), id)
+}
+
+// OrderContext is like Order, but the call is bound to the specified context.
+func (c *Client) OrderContext(ctx context.Context, id OrderID) (OrderDetail, error) {
+	rc := c.prepareRestCall(ctx)
 	rc.params = map[string]string{
-		"market":   market,
-		"quantity": strconv.FormatFloat(quantity, 'f', 8, 64),
-		"rate":     strconv.FormatFloat(rate, 'f', 8, 64),
+		"uuid": id.String(),
 	}
 
-	// If the result is successful, no error will be thrown. The only bit of
-	// information returned is the uuid of the sell order, which we don't care
-	// about. Therefore we ignore the response.
-	err := rc.doV1_1(api, true)
+	err := rc.doV1_1("account/getorder", true)
 	if err != nil {
-		return errors.Wrap(err, "market/buylimit failed")
+		return OrderDetail{}, errors.Wrap(err, "account/getorder failed")
 	}
 
-	return nil
+	var o OrderDetail
+	err = rc.decodeResult(&o)
+	if err != nil {
+		return OrderDetail{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	return o, nil
 }
 
 // Cancel sends a request to cancel an order with the specified UUID.
 func (c *Client) Cancel(orderUUID string) error {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
+	return c.CancelContext(context.Background(), orderUUID)
+}
+
+// CancelContext is like Cancel, but the call is bound to the specified context.
+func (c *Client) CancelContext(ctx context.Context, orderUUID string) error {
+	rc := c.prepareRestCall(ctx)
 	api := "market/cancel"
 
 	// Prepare the parameters.
@@ -180,71 +766,560 @@ func (c *Client) Cancel(orderUUID string) error {
 	return nil
 }
 
+// Withdraw sends a request to withdraw the specified quantity of a currency to
+// an address. The payment ID is optional and is used by some currencies to
+// identify the recipient at the address.
+//
+// The withdrawal is checked against the WithdrawalPolicy first, and a
+// WithdrawalRefusedError is returned if it is not allowed. In dry-run mode the
+// request is signed but not sent. Since a withdrawal can't be safely repeated,
+// a failed attempt is only retried if it never reached Bittrex.
+func (c *Client) Withdraw(currency string, quantity float64, address, paymentID string) (SubmittedWithdrawal, error) {
+	return c.WithdrawContext(context.Background(), currency, quantity, address, paymentID)
+}
+
+// WithdrawContext is like Withdraw, but the call is bound to the specified
+// context.
+func (c *Client) WithdrawContext(ctx context.Context, currency string, quantity float64, address, paymentID string) (SubmittedWithdrawal, error) {
+	policy := c.WithdrawalPolicy
+	event := WithdrawalAuditEvent{
+		Time:      time.Now().UTC(),
+		Currency:  currency,
+		Quantity:  quantity,
+		Address:   address,
+		PaymentID: paymentID,
+	}
+
+	w, err := c.withdraw(ctx, policy, currency, quantity, address, paymentID)
+	switch {
+	case IsWithdrawalRefused(err):
+		event.Outcome = WithdrawalRefused
+	case err != nil:
+		event.Outcome = WithdrawalFailed
+	case w.DryRun:
+		event.Outcome = WithdrawalSimulated
+	default:
+		event.Outcome = WithdrawalSent
+	}
+	event.ID = w.ID
+	event.Err = err
+
+	if policy != nil && policy.AuditHandler != nil {
+		policy.AuditHandler(event)
+	}
+
+	return w, err
+}
+
+// withdraw checks the withdrawal against the policy and then signs it and, if
+// the policy isn't in dry-run mode, sends it.
+func (c *Client) withdraw(ctx context.Context, policy *WithdrawalPolicy, currency string, quantity float64, address, paymentID string) (SubmittedWithdrawal, error) {
+	if reason := policy.check(currency, quantity, address, paymentID); reason != "" {
+		return SubmittedWithdrawal{}, &WithdrawalRefusedError{
+			Currency: currency,
+			Quantity: quantity,
+			Address:  address,
+			Reason:   reason,
+		}
+	}
+
+	rc := c.prepareRestCall(ctx)
+	api := "account/withdraw"
+
+	// Prepare the parameters.
+	rc.params = map[string]string{
+		"currency": currency,
+		"quantity": strconv.FormatFloat(quantity, 'f', 8, 64),
+		"address":  address,
+	}
+	if paymentID != "" {
+		rc.params["paymentid"] = paymentID
+	}
+
+	w := SubmittedWithdrawal{
+		Currency:  currency,
+		Quantity:  quantity,
+		Address:   address,
+		PaymentID: paymentID,
+	}
+
+	if policy.DryRun {
+		req, err := rc.requestV1_1(api, true)
+		if err != nil {
+			return SubmittedWithdrawal{}, errors.Wrap(err, "failed to sign the withdrawal")
+		}
+
+		w.DryRun = true
+		w.Request = req
+		return w, nil
+	}
+
+	// There is no reliable way to tell whether a failed withdrawal was
+	// applied, so it is only retried if it never reached Bittrex.
+	rc.mutating = true
+
+	err := rc.doV1_1(api, true)
+	if err != nil {
+		return SubmittedWithdrawal{}, errors.Wrap(err, "account/withdraw failed")
+	}
+
+	// The only bit of information returned is the uuid of the withdrawal.
+	var result struct {
+		UUID string `json:"uuid"`
+	}
+	err = rc.decodeResult(&result)
+	if err != nil {
+		return SubmittedWithdrawal{}, errors.Wrap(err, "json unmarshal failed")
+	}
+
+	w.ID = result.UUID
+	return w, nil
+}
+
 // Subscribe sends a request to Bittrex to start sending us the market data for
-// the indicated market.
+// the indicated market. If TrackOrderBooks is set, the local order book of the
+// market is seeded before Subscribe returns. Subscribing to a market that is
+// already subscribed to has no effect. If the subscription is still being
+// sent by another call, Subscribe waits for it and returns its outcome.
 func (c *Client) Subscribe(market string, errHandler ErrHandler) error {
-	err := c.websocketReady(errHandler)
+	return c.SubscribeContext(context.Background(), market, errHandler)
+}
+
+// SubscribeContext is like Subscribe, but starting the underlying SignalR
+// client and sending the subscription are bound to the specified context.
+func (c *Client) SubscribeContext(ctx context.Context, market string, errHandler ErrHandler) error {
+	attempt, first := c.addSubscription(market)
+	if attempt == nil {
+		return nil
+	}
+	if !first {
+		// Another call is sending the subscription, so it decides the
+		// outcome of this one.
+		select {
+		case <-attempt.done:
+			return attempt.err
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "subscription canceled")
+		}
+	}
+
+	err := c.subscribe(ctx, market, errHandler)
+	if err != nil {
+		// Forget about the market so the next call tries again.
+		c.Unsubscribe(market)
+	}
+	c.finishSubscription(market, attempt, err)
+
+	return err
+}
+
+// subscribe sends the subscription for the specified market and seeds its
+// order book.
+func (c *Client) subscribe(ctx context.Context, market string, errHandler ErrHandler) error {
+	err := c.websocketReady(ctx, errHandler)
 	if err != nil {
 		return errors.Wrap(err, "underlying signalr client is not ready")
 	}
 
-	msgs := []interface{}{market}
-	msgID := c.currentMsgID
-	c.currentMsgID++
-	hcm := hubs.ClientMsg{
-		H: "corehub",
-		M: "SubscribeToExchangeDeltas",
-		A: msgs,
-		I: msgID,
+	// Wait for our turn to send the subscription.
+	err = c.PublicRateLimiter.Wait(ctx)
+	if err != nil {
+		return errors.Wrap(err, "subscription canceled")
+	}
+
+	// Buffer the exchange updates that arrive before the book is seeded.
+	if c.TrackOrderBooks {
+		c.trackOrderBook(market)
+	}
+
+	err = c.sendSubscription(ctx, market)
+	if err != nil {
+		return err
+	}
+
+	if c.TrackOrderBooks {
+		err = c.seedOrderBook(ctx, market)
+		if err != nil {
+			return errors.Wrap(err, "failed to seed the order book")
+		}
 	}
 
-	err = c.signalrC.Send(hcm)
+	return nil
+}
+
+// sendSubscription sends the request to start sending us the market data for
+// the specified market and waits for the hub to accept it.
+func (c *Client) sendSubscription(ctx context.Context, market string) error {
+	return c.invokeSubscription(ctx, "SubscribeToExchangeDeltas", market)
+}
+
+// invokeSubscription calls a subscription method of the c2 hub. The hub
+// answers with true once the subscription is in place.
+func (c *Client) invokeSubscription(ctx context.Context, method string, args ...interface{}) error {
+	res, err := c.invoke(ctx, c2Hub, method, args...)
 	if err != nil {
-		return errors.Wrap(err, "failed to send via signalr client")
+		return err
+	}
+
+	var accepted bool
+	if json.Unmarshal(res, &accepted) == nil && !accepted {
+		return errors.Errorf("%s was rejected", method)
 	}
 
 	return nil
 }
 
+// watchConnection waits for the underlying SignalR client to replace its
+// connection, which it does when it reconnects after losing the connection.
+// The subscriptions don't carry over to the new connection, so they are sent
+// again.
+func (c *Client) watchConnection() {
+	done := c.closing()
+
+	// The SignalR client doesn't report reconnects, so we watch for the
+	// connection to change instead.
+	last := c.signalrC.Conn()
+	c.setConn(last)
+	ticker := time.NewTicker(connectionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		conn := c.signalrC.Conn()
+		if conn == last {
+			continue
+		}
+		last = conn
+		c.setConn(conn)
+
+		c.emitStreamEvent(Reconnected, "", nil)
+		c.resubscribe()
+	}
+}
+
+// connectionPollInterval is how often watchConnection checks for a new
+// connection.
+const connectionPollInterval = 100 * time.Millisecond
+
+// resubscribe sends the subscriptions again and resyncs the local order books,
+// since updates may have been missed while the connection was down.
+func (c *Client) resubscribe() {
+	// The authentication doesn't survive the connection either.
+	c.reauthenticate()
+	c.resubscribeSummaries()
+
+	for _, market := range c.Subscriptions() {
+		if c.isClosed() {
+			return
+		}
+
+		ctx, cancel := c.lifetimeContext(resyncTimeout)
+		err := c.PublicRateLimiter.Wait(ctx)
+		if err == nil {
+			err = c.sendSubscription(ctx, market)
+		}
+		cancel()
+		if err != nil {
+			err = errors.Wrapf(err, "failed to resubscribe to %s", market)
+			c.emitStreamEvent(ResubscribeFailed, market, err)
+			c.reportError(err)
+			continue
+		}
+
+		c.emitStreamEvent(Resubscribed, market, nil)
+		c.resyncOrderBookAfter(market, errors.New("the connection was reestablished"))
+	}
+}
+
+// Unsubscribe stops processing the market data of the indicated market and
+// discards its local order book. Bittrex has no way to stop sending the data,
+// so the updates that keep arriving for the market are ignored.
+func (c *Client) Unsubscribe(market string) {
+	c.subscriptionsMux.Lock()
+	delete(c.subscriptions, market)
+	c.subscriptionsMux.Unlock()
+
+	c.untrackOrderBook(market)
+	c.forgetTradeSequence(market)
+}
+
+// Subscriptions returns the markets that are subscribed to, in alphabetical
+// order.
+func (c *Client) Subscriptions() []string {
+	c.subscriptionsMux.Lock()
+	defer c.subscriptionsMux.Unlock()
+
+	markets := make([]string, 0, len(c.subscriptions))
+	for m := range c.subscriptions {
+		markets = append(markets, m)
+	}
+	sort.Strings(markets)
+
+	return markets
+}
+
+// subscriptionAttempt is a subscription that is being sent. Done is closed once
+// it is either in place or has failed, after which err holds the outcome.
+type subscriptionAttempt struct {
+	done chan struct{}
+	err  error
+}
+
+// addSubscription adds the market to the subscriptions, so that its updates
+// are processed while the subscription is being sent. It returns the attempt
+// to send the subscription, which is new if first is set, or nil if the market
+// was already subscribed to.
+func (c *Client) addSubscription(market string) (attempt *subscriptionAttempt, first bool) {
+	c.subscriptionsMux.Lock()
+	defer c.subscriptionsMux.Unlock()
+
+	if a, ok := c.subscribing[market]; ok {
+		return a, false
+	}
+	if c.subscriptions[market] {
+		return nil, false
+	}
+
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]bool)
+	}
+	c.subscriptions[market] = true
+
+	if c.subscribing == nil {
+		c.subscribing = make(map[string]*subscriptionAttempt)
+	}
+	attempt = &subscriptionAttempt{done: make(chan struct{})}
+	c.subscribing[market] = attempt
+
+	return attempt, true
+}
+
+// finishSubscription records the outcome of the attempt to subscribe to the
+// market and hands it to the calls that are waiting for it.
+func (c *Client) finishSubscription(market string, attempt *subscriptionAttempt, err error) {
+	c.subscriptionsMux.Lock()
+	delete(c.subscribing, market)
+	c.subscriptionsMux.Unlock()
+
+	attempt.err = err
+	close(attempt.done)
+}
+
+// subscribed indicates if the market is subscribed to.
+func (c *Client) subscribed(market string) bool {
+	c.subscriptionsMux.Lock()
+	defer c.subscriptionsMux.Unlock()
+	return c.subscriptions[market]
+}
+
+// nextMsgID returns the message ID to use for the next hub invocation.
+func (c *Client) nextMsgID() int {
+	c.invocationsMux.Lock()
+	defer c.invocationsMux.Unlock()
+	id := c.currentMsgID
+	c.currentMsgID++
+	return id
+}
+
+// DefaultInvocationTimeout is the default InvocationTimeout.
+const DefaultInvocationTimeout = 30 * time.Second
+
+// DefaultMessageBufferSize is the default MessageBufferSize.
+const DefaultMessageBufferSize = 1000
+
+// invoke calls a hub method and waits for its result, which is matched to the
+// invocation by its message ID. It gives up after InvocationTimeout, if that is
+// set. An error that the hub answers with is returned as a HubError. It is
+// safe to call from many goroutines at once, including the handlers, since the
+// results are delivered apart from the other messages, as long as fewer than
+// MessageBufferSize of those are waiting.
+func (c *Client) invoke(ctx context.Context, hub, method string, args ...interface{}) (json.RawMessage, error) {
+	if c.InvocationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.InvocationTimeout)
+		defer cancel()
+	}
+
+	id := c.nextMsgID()
+	key := strconv.Itoa(id)
+
+	// Prepare to receive the response before sending anything.
+	res := make(chan signalr.Message, 1)
+	c.invocationsMux.Lock()
+	if c.invocations == nil {
+		c.invocations = make(map[string]chan signalr.Message)
+	}
+	c.invocations[key] = res
+	c.invocationsMux.Unlock()
+
+	defer func() {
+		c.invocationsMux.Lock()
+		delete(c.invocations, key)
+		c.invocationsMux.Unlock()
+	}()
+
+	msg := hubs.ClientMsg{H: hub, M: method, A: append([]interface{}{}, args...), I: id}
+	err := c.signalrC.Send(msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send via signalr client")
+	}
+
+	select {
+	case msg := <-res:
+		if msg.E != "" {
+			return nil, &HubError{Hub: hub, Method: method, Message: msg.E}
+		}
+		return msg.R, nil
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "gave up waiting for the result of %s", method)
+	case <-c.closing():
+		return nil, errors.Errorf("gave up waiting for the result of %s: client is closed", method)
+	}
+}
+
+// deliverResponse hands the response to a hub invocation to whoever is waiting
+// for it.
+func (c *Client) deliverResponse(msg signalr.Message) {
+	c.invocationsMux.Lock()
+	res, ok := c.invocations[msg.I]
+	c.invocationsMux.Unlock()
+
+	if ok {
+		// The channel is buffered and only receives one response.
+		select {
+		case res <- msg:
+		default:
+		}
+	}
+}
+
 // Register saves the specified trade handler to a slice of handlers that will
-// be run against each incoming trade.
-func (c *Client) Register(h TradeHandler) {
-	c.tradeHandlersMux.Lock()
-	defer c.tradeHandlersMux.Unlock()
-	c.tradeHandlers = append(c.tradeHandlers, h)
+// be run against each incoming trade. If any markets are specified, the handler
+// is only run against their trades. The handler stays registered until the
+// returned registration is unregistered.
+func (c *Client) Register(h TradeHandler, markets ...string) *Registration {
+	th := c.register(h, false, markets...)
+	return newRegistration(func() { c.unregister(th) })
 }
 
-func (c *Client) websocketReady(errHandler ErrHandler) error {
+func (c *Client) websocketReady(ctx context.Context, errHandler ErrHandler) error {
 	// Return if no SignalR client exists.
 	if c.signalrC == nil {
 		return errors.New("underlying signalr client is not initialized")
 	}
 
+	// Don't start anything once the client is closed.
+	if c.isClosed() {
+		return errors.New("client is closed")
+	}
+
+	// Don't start anything on behalf of a caller that is no longer
+	// interested.
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "signalr client not started")
+	}
+
 	// Protect the started flag.
 	c.startedMux.Lock()
-	defer c.startedMux.Unlock()
 
 	// Return if the client has already been started.
 	if c.started {
+		c.startedMux.Unlock()
 		return nil
 	}
 
-	// Prepare a message channel.
+	// Start the client, unless another caller is already doing so.
+	if c.start == nil {
+		c.start = &startAttempt{done: make(chan struct{})}
+		go c.startWebsocket(c.start, errHandler)
+	}
+	start := c.start
+	c.startedMux.Unlock()
+
+	// Wait for the start attempt to finish. If the context is done first, the
+	// attempt carries on in the background so that later calls can use it.
+	select {
+	case <-start.done:
+		return start.err
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "gave up waiting for the SignalR client to start")
+	}
+}
+
+// startAttempt holds the result of an attempt to start the underlying SignalR
+// client. The done channel is closed once the attempt has finished.
+type startAttempt struct {
+	done chan struct{}
+	err  error
+}
+
+// startWebsocket starts the underlying SignalR client and records the result
+// in the specified attempt.
+func (c *Client) startWebsocket(start *startAttempt, errHandler ErrHandler) {
+	defer close(start.done)
+
+	// Prepare a message channel. Messages that are still in flight once the
+	// client is closed are discarded.
 	msgs := make(chan signalr.Message)
+	done := c.closing()
+	msgHandler := func(msg signalr.Message) {
+		select {
+		case msgs <- msg:
+		case <-done:
+		}
+	}
+
+	// Closing the connection makes the SignalR client report an error, which
+	// nobody is interested in anymore.
+	sigErrHandler := func(err error) {
+		if !c.isClosed() {
+			errHandler(err)
+		}
+	}
 
 	// Initialize the SignalR client.
-	msgHandler := func(msg signalr.Message) { msgs <- msg }
-	err := c.signalrC.Run(msgHandler, signalr.ErrHandler(errHandler))
+	err := c.signalrC.Run(msgHandler, sigErrHandler)
+
+	c.startedMux.Lock()
+	defer c.startedMux.Unlock()
+
+	// Forget about failed attempts so the next caller tries again.
+	c.start = nil
+
 	if err != nil {
-		return errors.Wrap(err, "failed to start the underlying SignalR client")
+		start.err = errors.Wrap(err, "failed to start the underlying SignalR client")
+		return
 	}
 
-	// Process the messages.
-	go c.processMessages(msgs, errHandler)
+	// Process the messages and restore the subscriptions after reconnects.
+	// If the client was closed while starting, the connection is closed
+	// again instead.
+	if !c.spawn(func() { c.processMessages(msgs, errHandler) }) {
+		start.err = errors.New("client is closed")
+		go c.setConn(c.signalrC.Conn())
+		return
+	}
+	c.spawn(c.watchConnection)
 
 	c.started = true
+	c.errHandler = errHandler
+}
 
-	return nil
+// reportError runs the error handler that the SignalR client was started with
+// against the error.
+func (c *Client) reportError(err error) {
+	c.startedMux.Lock()
+	h := c.errHandler
+	c.startedMux.Unlock()
+
+	if h != nil {
+		go h(err)
+	}
 }
 
 // Ticks gets a little under 10 days of candle data (14365 minutes) at the one
@@ -258,45 +1333,97 @@ func (c *Client) websocketReady(errHandler ErrHandler) error {
 // returned by this function. You shouldn't have to wait more than about 5-10
 // minutes for this to occur; at that point you likely only need to use live
 // trade data and perhaps then use this function as the source of truth for
-// validating your data.
+// validating your data. CandleSeries does all of this.
 //
-// Intervals provided by the underlying Bittrex API are: day, hour, thirtyMin,
-// fiveMin, and oneMin.
+// See TicksWithInterval for the other intervals.
 func (c *Client) Ticks(market string) ([]Tick, error) {
-	rc := newRestCall(c.HTTPClient, c.HTTPTransport, c.APIKey, c.APISecret, c.HostAddr)
-	interval := "oneMin"
+	return c.TicksContext(context.Background(), market)
+}
+
+// TicksContext is like Ticks, but the call is bound to the specified context.
+func (c *Client) TicksContext(ctx context.Context, market string) ([]Tick, error) {
+	return c.TicksWithIntervalContext(ctx, market, TickOneMin)
+}
+
+// TicksWithInterval is like Ticks, but gets the ticks of the specified
+// interval. The longer the interval, the further back the ticks go. The full
+// history of the interval is downloaded each time; see TicksInRange to narrow
+// it down afterwards.
+func (c *Client) TicksWithInterval(market string, interval TickInterval) ([]Tick, error) {
+	return c.TicksWithIntervalContext(context.Background(), market, interval)
+}
+
+// TicksWithIntervalContext is like TicksWithInterval, but the call is bound to
+// the specified context.
+func (c *Client) TicksWithIntervalContext(ctx context.Context, market string, interval TickInterval) ([]Tick, error) {
+	var ts []Tick
+	err := c.getTicks(ctx, "pub/market/GetTicks", market, interval, &ts)
+	if err != nil {
+		return []Tick{}, err
+	}
+	if ts == nil {
+		return []Tick{}, nil
+	}
+
+	return ts, nil
+}
+
+// LatestTick gets the latest tick of the specified interval.
+func (c *Client) LatestTick(market string, interval TickInterval) (Tick, error) {
+	return c.LatestTickContext(context.Background(), market, interval)
+}
+
+// LatestTickContext is like LatestTick, but the call is bound to the specified
+// context.
+func (c *Client) LatestTickContext(ctx context.Context, market string, interval TickInterval) (Tick, error) {
+	var ts []Tick
+	err := c.getTicks(ctx, "pub/market/GetLatestTick", market, interval, &ts)
+	if err != nil {
+		return Tick{}, err
+	}
+	if len(ts) == 0 {
+		return Tick{}, errors.Errorf("no latest tick returned for %s", market)
+	}
+
+	return ts[len(ts)-1], nil
+}
+
+// getTicks calls a tick endpoint and decodes its result into v, which is left
+// alone if there is no result.
+func (c *Client) getTicks(ctx context.Context, endpoint, market string, interval TickInterval, v interface{}) error {
+	if interval.Duration() == 0 {
+		return errors.Errorf("invalid tick interval: %d", int(interval))
+	}
+
+	rc := c.prepareRestCall(ctx)
 
 	// Set the parameters.
 	rc.params = map[string]string{
 		"marketName":   market,
-		"tickInterval": interval,
+		"tickInterval": interval.String(),
 	}
 
 	// Perform the API call.
-	err := rc.doV2_0("pub/market/GetTicks")
+	err := rc.doV2_0(endpoint)
 	if err != nil {
-		return []Tick{}, errors.Wrap(err, "pub/market/GetTicks failed")
+		return errors.Wrapf(err, "%s failed", endpoint)
 	}
 
-	// Extract the result into a usable variable.
-	result := rc.res.Result
-
 	// Return if no result was returned. This can happen even in successful
 	// situations. For example, the JSON response may look like this:
 	//
 	// {"success":true,"message":"","result":null}
-	if result == nil {
-		return []Tick{}, nil
+	if rc.res.Result == nil {
+		return nil
 	}
 
 	// Convert the results.
-	var ts []Tick
-	err = json.Unmarshal(*result, &ts)
+	err = rc.decodeResult(v)
 	if err != nil {
-		return []Tick{}, errors.Wrap(err, "json unmarshal failed")
+		return errors.Wrap(err, "json unmarshal failed")
 	}
 
-	return ts, nil
+	return nil
 }
 
 // SetCustomID assigns the specified id to the underlying SignalR client.
@@ -309,107 +1436,56 @@ func (c *Client) SetCustomID(id string) error {
 	return nil
 }
 
-// ProcessCandles monitors the trade data for all subscribed markets and
-// produces candle data for the specified interval.
-func (c *Client) ProcessCandles(interval time.Duration, candleHandler CandleHandler) {
-	// Register a handler to funnel each trade to a trades channel.
-	trades := make(chan Trade)
-	c.Register(func(t Trade) { trades <- t })
-
-	// Create a holding place for the candles for this interval.
-	candles := make(map[string]Candle)
-	candlesMux := sync.Mutex{}
-
-	// Start a goroutine that updates candle values as each trade comes in.
-	go func() {
-		for {
-			t := <-trades
-
-			candlesMux.Lock()
-
-			var candle Candle
-			var ok bool
-			if candle, ok = candles[t.Market()]; !ok {
-				// If the candle does not already exist, then create one.
-				candles[t.Market()] = Candle{
-					Market: t.Market(),
-					Open:   t.Price,
-					High:   t.Price,
-					Low:    t.Price,
-					Close:  t.Price,
-					Volume: t.Quantity,
-				}
-
-				// Move to the next trade.
-				candlesMux.Unlock()
-				continue
-			}
-
-			// If the candle does exist, then modify it as necessary.
+// DefaultCandleGracePeriod is the default CandleGracePeriod.
+const DefaultCandleGracePeriod = 5 * time.Second
 
-			// Set the open value if none is set.
-			if candle.Open == 0.0 {
-				candle.Open = t.Price
-			}
-
-			// Set the high value.
-			if t.Price > candle.High {
-				candle.High = t.Price
-			}
-
-			// Set the low value.
-			if t.Price < candle.Low {
-				candle.Low = t.Price
-			}
-
-			// Set the last price. This will eventually be used as the close
-			// value.
-			candle.Close = t.Price
+// ProcessCandles monitors the trade data for all subscribed markets and
+// produces candle data for the specified interval until the client is closed.
+//
+// Each trade goes into the candle that its own time falls in, and the candles
+// start on multiples of the interval in UTC. A candle is finalized and handed
+// to the handler CandleGracePeriod after its interval ends. Trades that arrive
+// later than that are dropped. Once a market has had a trade, an interval
+// without any trades produces a flat candle at the previous close, without any
+// volume. Besides the prices, each candle counts its trades, splits its volume
+// into buys and sells, and has its base volume and VWAP. To produce the
+// candles of several intervals at once, see AggregateCandles.
+//
+// The handler gets the candles in order of time and market, one at a time, so
+// a handler that takes long holds up the candles that follow.
+//
+// An error is returned if the interval is invalid or the client is closed, in
+// which case no candles are produced.
+func (c *Client) ProcessCandles(interval time.Duration, candleHandler CandleHandler) error {
+	a, err := c.candleAggregator(interval, candleHandler)
+	if err != nil {
+		return errors.Wrap(err, "failed to process candles")
+	}
 
-			// Increase the volume.
-			candle.Volume += t.Quantity
+	if !c.runCandleAggregator(a, nil, nil, func() {}) {
+		return errors.New("client is closed")
+	}
 
-			// Save the new value.
-			candles[t.Market()] = candle
+	return nil
+}
 
-			candlesMux.Unlock()
-		}
-	}()
+// candleAggregator creates a candle aggregator for the interval that uses the
+// CandleGracePeriod of the client, fills the gaps between the candles, and runs
+// the handler against each candle.
+func (c *Client) candleAggregator(interval time.Duration, h CandleHandler) (*CandleAggregator, error) {
+	a, err := NewCandleAggregator(interval)
+	if err != nil {
+		return nil, err
+	}
+	a.GracePeriod = c.CandleGracePeriod
+	a.FillGaps = true
 
-	// Start a goroutine that waits for the specified interval, prints the
-	// current candle values, and then resets the candle map.
-	go func() {
-		for {
-			// Wait for the specified interval.
-			<-time.After(interval)
-
-			// Save the current time.
-			now := time.Now()
-
-			// Lock the map.
-			candlesMux.Lock()
-
-			// Iterate over the candles for this interval.
-			for k, v := range candles {
-				// Set the time to now minus the specified interval. This
-				// represents when the interval started.
-				v.Time = now.Add(-1 * interval)
-
-				// Process the candle.
-				go candleHandler(v)
-
-				// Update the candle values so they carry over data to the next
-				// interval.
-				v.Open = v.Close
-				v.High = v.Close
-				v.Low = v.Close
-				candles[k] = v
-			}
+	_, err = a.Register(interval, h)
+	if err != nil {
+		return nil, err
+	}
 
-			// Unlock the map.
-			candlesMux.Unlock()
-		}
-	}()
+	return a, nil
 }
 
 // New creates a new Bittrex client.
@@ -423,6 +1499,33 @@ func New(apiKey, apiSecret string) *Client {
 	// Set the default host address.
 	c.HostAddr = "https://bittrex.com"
 
+	// Set the REST retry defaults.
+	c.MaxRetries = 3
+	c.RetryWaitDuration = 1 * time.Second
+
+	// Stay within the documented limit of 60 requests per minute.
+	c.PublicRateLimiter = NewRateLimiter(1, 60)
+	c.PrivateRateLimiter = NewRateLimiter(1, 60)
+
+	// Wait for the trades that are held up by a missing exchange update
+	// before finalizing a candle.
+	c.CandleGracePeriod = DefaultCandleGracePeriod
+
+	// Don't wait forever for a hub that never answers.
+	c.InvocationTimeout = DefaultInvocationTimeout
+
+	// Let the responses to hub invocations get past a burst of messages that
+	// the handlers are still working through.
+	c.MessageBufferSize = DefaultMessageBufferSize
+
+	// Bittrex sends updates about once a second, so a missing one won't show
+	// up after a couple of seconds.
+	c.NonceGapTimeout = 2 * time.Second
+
+	// Give the ordered trade handlers some slack before the overflow policy
+	// kicks in.
+	c.QueueSize = DefaultQueueSize
+
 	// Set up the underlying SignalR client.
 	signalrC := signalr.New(
 		"socket.bittrex.com",
@@ -453,54 +1556,110 @@ type CandleHandler func(c Candle)
 // ErrHandler processes an error.
 type ErrHandler func(err error)
 
-// This processes SignalR messages.
+// This processes SignalR messages until the client is closed. The responses to
+// hub invocations are delivered as soon as they arrive, and the other messages
+// are buffered for processData, so that a handler that blocks or a message
+// that can't be processed doesn't hold up the responses. Once
+// MessageBufferSize messages are waiting, no more are read until there is room
+// for them.
 func (c *Client) processMessages(msgs chan signalr.Message, errHandler ErrHandler) {
-	for msg := range msgs {
-		if !c.processMessage(msg, errHandler) {
+	done := c.closing()
+
+	size := c.MessageBufferSize
+	if size < 0 {
+		size = 0
+	}
+	data := make(chan signalr.Message, size)
+	if !c.spawn(func() { c.processData(data, errHandler) }) {
+		return
+	}
+
+	for {
+		select {
+		case msg := <-msgs:
+			// Responses to invocations don't hold any Bittrex messages.
+			if msg.I != "" {
+				c.deliverResponse(msg)
+				continue
+			}
+
+			select {
+			case data <- msg:
+			case <-done:
+				return
+			}
+		case <-done:
 			return
 		}
 	}
 }
 
-// Process a single SignalR message.
-func (c *Client) processMessage(msg signalr.Message, errHandler ErrHandler) bool {
-	// Assume that the message is successfully processed. Prove otherwise.
-	ok := true
+// processData processes the buffered SignalR messages in order until the
+// client is closed. Messages that can't be processed are reported to the error
+// handler and skipped.
+func (c *Client) processData(data chan signalr.Message, errHandler ErrHandler) {
+	done := c.closing()
+
+	for {
+		select {
+		case msg := <-data:
+			c.processMessage(msg, errHandler)
+		case <-done:
+			return
+		}
+	}
+}
 
+// Process a single SignalR message that holds Bittrex messages.
+func (c *Client) processMessage(msg signalr.Message, errHandler ErrHandler) {
 	// Within each SignalR message is a slice of Bittrex messages.
 	for _, bittrexMsg := range msg.M {
-		// Verify the Bittrex message type is updateExchangeState.
-		if bittrexMsg.M != "updateExchangeState" {
+		var process func(arg interface{}, errHandler ErrHandler)
+		switch bittrexMsg.M {
+		case c2ExchangeDeltasMethod:
+			process = c.processBittrexMsgArg
+		case c2OrderDeltaMethod:
+			process = c.processOrderDelta
+		case c2BalanceDeltaMethod:
+			process = c.processBalanceDelta
+		case c2SummaryDeltasMethod:
+			process = c.processSummaryDeltas
+		case c2LiteSummaryDeltasMethod:
+			process = c.processSummaryLiteDeltas
+		case authenticationExpiringMethod:
+			c.spawn(c.reauthenticate)
+			continue
+		default:
 			continue
 		}
 
-		// Process each of the arguments.
+		// Process each of the arguments. Each one reports its own errors, so
+		// an argument that can't be processed doesn't hold up the others.
 		for _, arg := range bittrexMsg.A {
-			ok = c.processBittrexMsgArg(arg, errHandler)
-			if !ok {
-				return false
-			}
+			process(arg, errHandler)
 		}
 	}
-
-	return ok
 }
 
-// Process a single argument from a Bittrex message.
-func (c *Client) processBittrexMsgArg(arg interface{}, errHandler ErrHandler) bool {
-	data, err := json.Marshal(arg)
+// Process a single argument from a Bittrex message. Fills that can't be
+// processed are reported and left out of the trades.
+func (c *Client) processBittrexMsgArg(arg interface{}, errHandler ErrHandler) {
+	var deltas c2ExchangeDeltas
+	err := decodeC2Arg(arg, &deltas)
 	if err != nil {
-		go errHandler(errors.Wrap(err, "json marshal failed"))
-		return false
+		go errHandler(errors.Wrap(err, "failed to decode the exchange deltas"))
+		return
 	}
+	eu := deltas.exchangeUpdate()
 
-	var eu exchangeUpdate
-	err = json.Unmarshal(data, &eu)
-	if err != nil {
-		go errHandler(errors.Wrap(err, "json unmarshal failed"))
-		return false
+	// Ignore the markets that were unsubscribed from.
+	if !c.subscribed(eu.MarketName) {
+		return
 	}
 
+	c.updateOrderBook(eu)
+
+	b := tradeBatch{market: eu.MarketName, nonce: eu.Nounce}
 	for _, t := range eu.Fills {
 		marketParts := strings.Split(eu.MarketName, "-")
 		bc := marketParts[0]
@@ -514,7 +1673,7 @@ func (c *Client) processBittrexMsgArg(arg interface{}, errHandler ErrHandler) bo
 			tType = SellType
 		default:
 			go errHandler(errors.Errorf("invalid trade type: %v", t.OrderType))
-			return false
+			continue
 		}
 
 		// Parse the time.
@@ -522,7 +1681,7 @@ func (c *Client) processBittrexMsgArg(arg interface{}, errHandler ErrHandler) bo
 		parsedTime, err = t.time()
 		if err != nil {
 			go errHandler(errors.Wrap(err, "time parse error"))
-			return false
+			continue
 		}
 
 		// Create a new trade.
@@ -535,15 +1694,11 @@ func (c *Client) processBittrexMsgArg(arg interface{}, errHandler ErrHandler) bo
 			Time:           parsedTime,
 		}
 
-		// Process the trade using the trade handlers.
-		c.tradeHandlersMux.Lock()
-		for _, h := range c.tradeHandlers {
-			go h(t)
-		}
-		c.tradeHandlersMux.Unlock()
+		b.trades = append(b.trades, t)
 	}
 
-	// If we made it to this point, then the argument was successfully
-	// processed.
-	return true
+	// Process the trades using the trade handlers. The batch is delivered
+	// even if fills were left out, since its nonce keeps the ordered
+	// handlers going.
+	c.deliverTrades(b)
 }
@@ -1,15 +1,21 @@
 package bittrex
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	cfscraper "github.com/carterjones/go-cloudflare-scraper"
 	"github.com/carterjones/signalr"
+	"github.com/carterjones/signalr/hubs"
+	"github.com/pkg/errors"
 )
 
 func TestClient_SetCustomID(t *testing.T) {
@@ -42,38 +48,184 @@ func TestClient_SetCustomID(t *testing.T) {
 
 func TestClient_Subscribe(t *testing.T) {
 	cases := map[string]struct {
-		client   *Client
-		serverFn http.HandlerFunc
-		wantErr  string
+		serverFn    http.HandlerFunc
+		handler     InvocationHandler
+		timeout     time.Duration
+		wantErr     string
+		wantHubErr  bool
+		wantStarted bool
 	}{
 		"normal": {
-			client:   New("my-key", "my-secret"),
-			serverFn: signalr.TestCompleteHandler,
+			handler:     func(hubs.ClientMsg) (interface{}, string) { return true, "" },
+			wantStarted: true,
 		},
 		"initialization failure": {
-			client:   New("my-key", "my-secret"),
 			serverFn: func(w http.ResponseWriter, r *http.Request) {},
 			wantErr:  "failed to start the underlying SignalR client",
 		},
+		"hub error": {
+			handler:     func(hubs.ClientMsg) (interface{}, string) { return nil, "unknown market" },
+			wantErr:     "SubscribeToExchangeDeltas failed: unknown market",
+			wantHubErr:  true,
+			wantStarted: true,
+		},
+		"rejected": {
+			handler:     func(hubs.ClientMsg) (interface{}, string) { return false, "" },
+			wantErr:     "SubscribeToExchangeDeltas was rejected",
+			wantStarted: true,
+		},
+		"no result": {
+			handler: func(hubs.ClientMsg) (interface{}, string) {
+				time.Sleep(200 * time.Millisecond)
+				return true, ""
+			},
+			timeout:     50 * time.Millisecond,
+			wantErr:     "gave up waiting for the result of SubscribeToExchangeDeltas: context deadline exceeded",
+			wantStarted: true,
+		},
+	}
+
+	for id, tc := range cases {
+		var ts *httptest.Server
+		if tc.handler != nil {
+			ts = NewMockSignalRServer(tc.handler).Server
+		} else {
+			ts = httptest.NewServer(tc.serverFn)
+		}
+
+		c := New("my-key", "my-secret")
+		if tc.timeout != 0 {
+			c.InvocationTimeout = tc.timeout
+		}
+		c.HTTPClient = ts.Client()
+		c.HostAddr = ts.URL
+		c.signalrC.Host = strings.Replace(ts.URL, "http://", "", -1)
+		c.signalrC.Scheme = signalr.HTTP
+
+		err := c.Subscribe("BTC-LTC", func(error) {})
+		equals(t, id, tc.wantStarted, c.started)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+			equals(t, id, tc.wantHubErr, IsHubError(err))
+			equals(t, id, []string{}, c.Subscriptions())
+		} else {
+			ok(t, id, err)
+			equals(t, id, []string{"BTC-LTC"}, c.Subscriptions())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ok(t, id, c.Close(ctx))
+		cancel()
+		ts.Close()
+	}
+}
+
+func TestClient_invoke(t *testing.T) {
+	ts := NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		return m.A[0], ""
+	})
+	defer ts.Close()
+
+	c := New("", "")
+	ts.ConfigureClient(c)
+	ok(t, "ready", c.websocketReady(context.Background(), func(error) {}))
+
+	// Each result reaches the invocation that asked for it, however many run
+	// at once.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			res, err := c.invoke(context.Background(), c2Hub, "Echo", strconv.Itoa(i))
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			var echo string
+			err = json.Unmarshal(res, &echo)
+			if err == nil && echo != strconv.Itoa(i) {
+				err = errors.Errorf("invocation %d got the result of invocation %s", i, echo)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		ok(t, "concurrent", err)
+	}
+	equals(t, "pending", 0, len(c.invocations))
+}
+
+func TestClient_limitOrder(t *testing.T) {
+	openOrder := `{"success":true,"message":"","result":[{"OrderUuid":"09aa5bb6-8232-41aa-9b78-a5a1093e0211","Exchange":"BTC-LTC","OrderType":"LIMIT_BUY","Quantity":5.0,"QuantityRemaining":5.0,"Limit":0.00002,"Opened":"2014-07-09T03:55:48.77"}]}`
+	noOrders := `{"success":true,"message":"","result":[]}`
+
+	cases := map[string]struct {
+		openOrders   string
+		orderHistory string
+		expHits      int
+		wantErr      string
+	}{
+		"order not placed": {
+			openOrders:   noOrders,
+			orderHistory: noOrders,
+			expHits:      2,
+		},
+		"order placed": {
+			openOrders:   openOrder,
+			orderHistory: noOrders,
+			expHits:      1,
+			wantErr:      "request was applied, not retrying",
+		},
+		"lookup failure": {
+			openOrders:   `{"success":false,"message":"APIKEY_INVALID","result":null}`,
+			orderHistory: noOrders,
+			expHits:      1,
+			wantErr:      "failed to get open orders",
+		},
 	}
 
 	for id, tc := range cases {
-		ts := httptest.NewUnstartedServer(http.HandlerFunc(tc.serverFn))
-		ts.Start()
+		var hits int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1.1/market/buylimit":
+				hits++
+				if hits == 1 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte(`{"success":true,"message":"","result":{"uuid":"e606d53c-8d70-11e3-94b5-425861b86ab6"}}`))
+			case "/api/v1.1/market/getopenorders":
+				w.Write([]byte(tc.openOrders))
+			case "/api/v1.1/account/getorderhistory":
+				w.Write([]byte(tc.orderHistory))
+			}
+		}))
 
-		tc.client.HTTPClient = ts.Client()
-		tc.client.HostAddr = ts.URL
-		tc.client.signalrC.Host = strings.Replace(ts.URL, "http://", "", -1)
-		tc.client.signalrC.Scheme = signalr.HTTP
+		c := New("my-key", "my-secret")
+		c.HTTPClient = ts.Client()
+		c.HostAddr = ts.URL
+		c.RetryWaitDuration = time.Millisecond
 
-		err := tc.client.Subscribe("BTC-LTC", func(error) {})
+		_, err := c.limitOrder(context.Background(), "market/buylimit", BuyType, "BTC-LTC", 5.0, 0.00002)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
-			equals(t, id, false, tc.client.started)
 		} else {
-			equals(t, id, true, tc.client.started)
 			ok(t, id, err)
 		}
+		equals(t, id, tc.expHits, hits)
+
+		ts.Close()
 	}
 }
 
@@ -87,9 +239,16 @@ func TestNewUnstarted(t *testing.T) {
 			apiKey:    "my-key",
 			apiSecret: "my-secret",
 			exp: &Client{
-				APIKey:    "my-key",
-				APISecret: "my-secret",
-				HostAddr:  "https://bittrex.com",
+				APIKey:            "my-key",
+				APISecret:         "my-secret",
+				HostAddr:          "https://bittrex.com",
+				MaxRetries:        3,
+				RetryWaitDuration: 1 * time.Second,
+				NonceGapTimeout:   2 * time.Second,
+				QueueSize:         DefaultQueueSize,
+				InvocationTimeout: DefaultInvocationTimeout,
+				MessageBufferSize: DefaultMessageBufferSize,
+				CandleGracePeriod: DefaultCandleGracePeriod,
 			},
 		},
 	}
@@ -123,9 +282,17 @@ func equalsClient(t *testing.T, id string, c1 *Client, c2 *Client) {
 	// Verify the retry wait duration default was set.
 	equals(t, id, c1.signalrC.RetryWaitDuration, 10*time.Second)
 
-	// Zero out the SignalR client and compare the rest of the Bittrex
-	// client structure.
+	// Verify the default rate limits were set.
+	equals(t, id, 1.0, c1.PublicRateLimiter.rate)
+	equals(t, id, 60.0, c1.PublicRateLimiter.burst)
+	equals(t, id, 1.0, c1.PrivateRateLimiter.rate)
+	equals(t, id, 60.0, c1.PrivateRateLimiter.burst)
+
+	// Zero out the SignalR client and rate limiters and compare the rest of
+	// the Bittrex client structure.
 	c1.signalrC = nil
 	c2.signalrC = nil
+	c1.PublicRateLimiter = nil
+	c1.PrivateRateLimiter = nil
 	equals(t, id, c2, c1)
 }
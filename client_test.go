@@ -1,13 +1,21 @@
 package bittrex_test
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/carterjones/bittrex"
+	"github.com/carterjones/signalr/hubs"
 )
 
 func mustReadTestFixture(fixture string) []byte {
@@ -16,6 +24,13 @@ func mustReadTestFixture(fixture string) []byte {
 	return data
 }
 
+// mustEncodeC2 encodes v as a c2 payload.
+func mustEncodeC2(v interface{}) string {
+	payload, err := bittrex.EncodeC2Payload(v)
+	panicIfErr(err)
+	return payload
+}
+
 func panicIfErr(err error) {
 	if err != nil {
 		panic(err)
@@ -23,10 +38,22 @@ func panicIfErr(err error) {
 }
 
 var (
-	fixtureAccountGetbalances     = mustReadTestFixture("account_getbalances.json")
-	fixtureAccountGetorderhistory = mustReadTestFixture("account_getorderhistory.json")
-	fixturePubGetticks            = mustReadTestFixture("pub_market_getticks.json")
-	fixturePublicGetmarkets       = mustReadTestFixture("public_getmarkets.json")
+	fixtureAccountGetbalance           = mustReadTestFixture("account_getbalance.json")
+	fixtureAccountGetbalances          = mustReadTestFixture("account_getbalances.json")
+	fixtureAccountGetdepositaddress    = mustReadTestFixture("account_getdepositaddress.json")
+	fixtureAccountGetdeposithistory    = mustReadTestFixture("account_getdeposithistory.json")
+	fixtureAccountGetorder             = mustReadTestFixture("account_getorder.json")
+	fixtureAccountGetorderhistory      = mustReadTestFixture("account_getorderhistory.json")
+	fixtureAccountGetwithdrawalhistory = mustReadTestFixture("account_getwithdrawalhistory.json")
+	fixtureMarketGetopenorders         = mustReadTestFixture("market_getopenorders.json")
+	fixturePubGetticks                 = mustReadTestFixture("pub_market_getticks.json")
+	fixturePublicGetmarkets            = mustReadTestFixture("public_getmarkets.json")
+	fixturePublicGetticker             = mustReadTestFixture("public_getticker.json")
+	fixturePublicGetmarketsummaries    = mustReadTestFixture("public_getmarketsummaries.json")
+	fixturePublicGetmarketsummary      = mustReadTestFixture("public_getmarketsummary.json")
+	fixturePublicGetorderbook          = mustReadTestFixture("public_getorderbook.json")
+	fixturePublicGetmarkethistory      = mustReadTestFixture("public_getmarkethistory.json")
+	fixturePublicGetcurrencies         = mustReadTestFixture("public_getcurrencies.json")
 )
 
 func TestClient_Markets(t *testing.T) {
@@ -64,19 +91,21 @@ func TestClient_Markets(t *testing.T) {
 	}
 }
 
-func TestClient_Balances(t *testing.T) {
+func TestClient_Ticker(t *testing.T) {
 	cases := map[string]struct {
 		client  *bittrex.Client
-		exp     []bittrex.Balance
+		market  string
+		exp     bittrex.Ticker
 		wantErr string
 	}{
 		"normal": {
 			client: bittrex.New("", ""),
-			exp: func() []bittrex.Balance {
+			market: "BTC-ETH",
+			exp: func() bittrex.Ticker {
 				var result struct {
-					Result []bittrex.Balance `json:"result"`
+					Result bittrex.Ticker `json:"result"`
 				}
-				err := json.Unmarshal(fixtureAccountGetbalances, &result)
+				err := json.Unmarshal(fixturePublicGetticker, &result)
 				panicIfErr(err)
 				return result.Result
 			}(),
@@ -84,34 +113,35 @@ func TestClient_Balances(t *testing.T) {
 	}
 
 	for id, tc := range cases {
-		ts, _ := bittrex.NewMockRestServer()
+		ts, rr := bittrex.NewMockRestServer()
 		ts.Start()
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		act, err := tc.client.Balances()
+		act, err := tc.client.Ticker(tc.market)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
 			equals(t, id, tc.exp, act)
+			equals(t, id, tc.market, rr.Params.Get("market"))
 			ok(t, id, err)
 		}
 	}
 }
 
-func TestClient_OrderHistory(t *testing.T) {
+func TestClient_MarketSummaries(t *testing.T) {
 	cases := map[string]struct {
 		client  *bittrex.Client
-		exp     []bittrex.Order
+		exp     []bittrex.MarketSummary
 		wantErr string
 	}{
 		"normal": {
 			client: bittrex.New("", ""),
-			exp: func() []bittrex.Order {
+			exp: func() []bittrex.MarketSummary {
 				var result struct {
-					Result []bittrex.Order `json:"result"`
+					Result []bittrex.MarketSummary `json:"result"`
 				}
-				err := json.Unmarshal(fixtureAccountGetorderhistory, &result)
+				err := json.Unmarshal(fixturePublicGetmarketsummaries, &result)
 				panicIfErr(err)
 				return result.Result
 			}(),
@@ -124,7 +154,7 @@ func TestClient_OrderHistory(t *testing.T) {
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		act, err := tc.client.OrderHistory()
+		act, err := tc.client.MarketSummaries()
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
@@ -134,19 +164,24 @@ func TestClient_OrderHistory(t *testing.T) {
 	}
 }
 
-func TestClient_LimitSell(t *testing.T) {
+func TestClient_MarketSummary(t *testing.T) {
 	cases := map[string]struct {
-		client   *bittrex.Client
-		market   string
-		quantity float64
-		rate     float64
-		wantErr  string
+		client  *bittrex.Client
+		market  string
+		exp     bittrex.MarketSummary
+		wantErr string
 	}{
 		"normal": {
-			client:   bittrex.New("", ""),
-			market:   "BTC-ETH",
-			quantity: 9001.0,
-			rate:     1000.9,
+			client: bittrex.New("", ""),
+			market: "BTC-ETH",
+			exp: func() bittrex.MarketSummary {
+				var result struct {
+					Result []bittrex.MarketSummary `json:"result"`
+				}
+				err := json.Unmarshal(fixturePublicGetmarketsummary, &result)
+				panicIfErr(err)
+				return result.Result[0]
+			}(),
 		},
 	}
 
@@ -156,33 +191,56 @@ func TestClient_LimitSell(t *testing.T) {
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		err := tc.client.LimitSell(tc.market, tc.quantity, tc.rate)
+		act, err := tc.client.MarketSummary(tc.market)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
-			expQuantity := fmt.Sprintf("%.8f", tc.quantity)
-			expRate := fmt.Sprintf("%.8f", tc.rate)
+			equals(t, id, tc.exp, act)
 			equals(t, id, tc.market, rr.Params.Get("market"))
-			equals(t, id, expQuantity, rr.Params.Get("quantity"))
-			equals(t, id, expRate, rr.Params.Get("rate"))
 			ok(t, id, err)
 		}
 	}
 }
 
-func TestClient_LimitBuy(t *testing.T) {
+func TestClient_OrderBook(t *testing.T) {
+	book := func() bittrex.OrderBook {
+		var result struct {
+			Result bittrex.OrderBook `json:"result"`
+		}
+		err := json.Unmarshal(fixturePublicGetorderbook, &result)
+		panicIfErr(err)
+		result.Result.Market = "BTC-ETH"
+		return result.Result
+	}()
+
 	cases := map[string]struct {
 		client   *bittrex.Client
 		market   string
-		quantity float64
-		rate     float64
+		side     bittrex.OrderBookSide
+		depth    int
+		exp      bittrex.OrderBook
+		expDepth string
 		wantErr  string
 	}{
-		"normal": {
+		"both sides": {
+			client: bittrex.New("", ""),
+			market: "BTC-ETH",
+			side:   bittrex.BothSides,
+			exp:    book,
+		},
+		"buy side": {
 			client:   bittrex.New("", ""),
 			market:   "BTC-ETH",
-			quantity: 9001.0,
-			rate:     1000.9,
+			side:     bittrex.BuySide,
+			depth:    20,
+			exp:      bittrex.OrderBook{Market: "BTC-ETH", Buy: book.Buy},
+			expDepth: "20",
+		},
+		"sell side": {
+			client: bittrex.New("", ""),
+			market: "BTC-ETH",
+			side:   bittrex.SellSide,
+			exp:    bittrex.OrderBook{Market: "BTC-ETH", Sell: book.Sell},
 		},
 	}
 
@@ -192,28 +250,37 @@ func TestClient_LimitBuy(t *testing.T) {
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		err := tc.client.LimitBuy(tc.market, tc.quantity, tc.rate)
+		act, err := tc.client.OrderBook(tc.market, tc.side, tc.depth)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
-			expQuantity := fmt.Sprintf("%.8f", tc.quantity)
-			expRate := fmt.Sprintf("%.8f", tc.rate)
+			equals(t, id, tc.exp, act)
 			equals(t, id, tc.market, rr.Params.Get("market"))
-			equals(t, id, expQuantity, rr.Params.Get("quantity"))
-			equals(t, id, expRate, rr.Params.Get("rate"))
+			equals(t, id, string(tc.side), rr.Params.Get("type"))
+			equals(t, id, tc.expDepth, rr.Params.Get("depth"))
 			ok(t, id, err)
 		}
 	}
 }
 
-func TestClient_Cancel(t *testing.T) {
+func TestClient_MarketHistory(t *testing.T) {
 	cases := map[string]struct {
-		client    *bittrex.Client
-		orderUUID string
-		wantErr   string
+		client  *bittrex.Client
+		market  string
+		exp     []bittrex.MarketTrade
+		wantErr string
 	}{
 		"normal": {
 			client: bittrex.New("", ""),
+			market: "BTC-ETH",
+			exp: func() []bittrex.MarketTrade {
+				var result struct {
+					Result []bittrex.MarketTrade `json:"result"`
+				}
+				err := json.Unmarshal(fixturePublicGetmarkethistory, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
 		},
 	}
 
@@ -223,31 +290,30 @@ func TestClient_Cancel(t *testing.T) {
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		err := tc.client.Cancel(tc.orderUUID)
+		act, err := tc.client.MarketHistory(tc.market)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
-			equals(t, id, tc.orderUUID, rr.Params.Get("uuid"))
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.market, rr.Params.Get("market"))
 			ok(t, id, err)
 		}
 	}
 }
 
-func TestClient_Ticks(t *testing.T) {
+func TestClient_Currencies(t *testing.T) {
 	cases := map[string]struct {
 		client  *bittrex.Client
-		market  string
-		exp     []bittrex.Tick
+		exp     []bittrex.Currency
 		wantErr string
 	}{
 		"normal": {
 			client: bittrex.New("", ""),
-			market: "BTC-WAVES",
-			exp: func() []bittrex.Tick {
+			exp: func() []bittrex.Currency {
 				var result struct {
-					Result []bittrex.Tick `json:"result"`
+					Result []bittrex.Currency `json:"result"`
 				}
-				err := json.Unmarshal(fixturePubGetticks, &result)
+				err := json.Unmarshal(fixturePublicGetcurrencies, &result)
 				panicIfErr(err)
 				return result.Result
 			}(),
@@ -260,12 +326,2061 @@ func TestClient_Ticks(t *testing.T) {
 		tc.client.HTTPClient = ts.Client()
 		tc.client.HostAddr = ts.URL
 
-		act, err := tc.client.Ticks(tc.market)
+		act, err := tc.client.Currencies()
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_Balances(t *testing.T) {
+	cases := map[string]struct {
+		client  *bittrex.Client
+		exp     []bittrex.Balance
+		wantErr string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+			exp: func() []bittrex.Balance {
+				var result struct {
+					Result []bittrex.Balance `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetbalances, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, _ := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.Balances()
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_OrderHistory(t *testing.T) {
+	cases := map[string]struct {
+		client  *bittrex.Client
+		exp     []bittrex.Order
+		wantErr string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+			exp: func() []bittrex.Order {
+				var result struct {
+					Result []bittrex.Order `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetorderhistory, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, _ := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.OrderHistory()
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_OpenOrders(t *testing.T) {
+	cases := map[string]struct {
+		client  *bittrex.Client
+		market  string
+		exp     []bittrex.OpenOrder
+		wantErr string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+			market: "BTC-LTC",
+			exp: func() []bittrex.OpenOrder {
+				var result struct {
+					Result []bittrex.OpenOrder `json:"result"`
+				}
+				err := json.Unmarshal(fixtureMarketGetopenorders, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.OpenOrders(tc.market)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.market, rr.Params.Get("market"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_Balance(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		currency string
+		exp      bittrex.Balance
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			currency: "BTC",
+			exp: func() bittrex.Balance {
+				var result struct {
+					Result bittrex.Balance `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetbalance, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.Balance(tc.currency)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.currency, rr.Params.Get("currency"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_DepositAddress(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		currency string
+		exp      bittrex.DepositAddress
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			currency: "VTC",
+			exp: func() bittrex.DepositAddress {
+				var result struct {
+					Result bittrex.DepositAddress `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetdepositaddress, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.DepositAddress(tc.currency)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
 			equals(t, id, tc.exp, act)
+			equals(t, id, tc.currency, rr.Params.Get("currency"))
 			ok(t, id, err)
 		}
 	}
 }
+
+func TestClient_DepositHistory(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		currency string
+		exp      []bittrex.Deposit
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			currency: "BTC",
+			exp: func() []bittrex.Deposit {
+				var result struct {
+					Result []bittrex.Deposit `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetdeposithistory, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.DepositHistory(tc.currency)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.currency, rr.Params.Get("currency"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_WithdrawalHistory(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		currency string
+		exp      []bittrex.Withdrawal
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			currency: "BTC",
+			exp: func() []bittrex.Withdrawal {
+				var result struct {
+					Result []bittrex.Withdrawal `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetwithdrawalhistory, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.WithdrawalHistory(tc.currency)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.currency, rr.Params.Get("currency"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_Order(t *testing.T) {
+	cases := map[string]struct {
+		client  *bittrex.Client
+		id      bittrex.OrderID
+		exp     bittrex.OrderDetail
+		wantErr string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+			id:     "0cb4c4e4-bdc7-4e13-8c13-430e587d2cc1",
+			exp: func() bittrex.OrderDetail {
+				var result struct {
+					Result bittrex.OrderDetail `json:"result"`
+				}
+				err := json.Unmarshal(fixtureAccountGetorder, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.Order(tc.id)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			equals(t, id, tc.id.String(), rr.Params.Get("uuid"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_LimitSell(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		market   string
+		quantity float64
+		rate     float64
+		exp      bittrex.PlacedOrder
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			market:   "BTC-ETH",
+			quantity: 9001.0,
+			rate:     1000.9,
+			exp: bittrex.PlacedOrder{
+				ID:       "614c34e4-8d71-11e3-94b5-425861b86ab6",
+				Market:   "BTC-ETH",
+				Type:     bittrex.SellType,
+				Quantity: 9001.0,
+				Rate:     1000.9,
+			},
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.LimitSell(tc.market, tc.quantity, tc.rate)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			expQuantity := fmt.Sprintf("%.8f", tc.quantity)
+			expRate := fmt.Sprintf("%.8f", tc.rate)
+			equals(t, id, tc.market, rr.Params.Get("market"))
+			equals(t, id, expQuantity, rr.Params.Get("quantity"))
+			equals(t, id, expRate, rr.Params.Get("rate"))
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_LimitBuy(t *testing.T) {
+	cases := map[string]struct {
+		client   *bittrex.Client
+		market   string
+		quantity float64
+		rate     float64
+		exp      bittrex.PlacedOrder
+		wantErr  string
+	}{
+		"normal": {
+			client:   bittrex.New("", ""),
+			market:   "BTC-ETH",
+			quantity: 9001.0,
+			rate:     1000.9,
+			exp: bittrex.PlacedOrder{
+				ID:       "e606d53c-8d70-11e3-94b5-425861b86ab6",
+				Market:   "BTC-ETH",
+				Type:     bittrex.BuyType,
+				Quantity: 9001.0,
+				Rate:     1000.9,
+			},
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.LimitBuy(tc.market, tc.quantity, tc.rate)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			expQuantity := fmt.Sprintf("%.8f", tc.quantity)
+			expRate := fmt.Sprintf("%.8f", tc.rate)
+			equals(t, id, tc.market, rr.Params.Get("market"))
+			equals(t, id, expQuantity, rr.Params.Get("quantity"))
+			equals(t, id, expRate, rr.Params.Get("rate"))
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_Withdraw(t *testing.T) {
+	allowed := []bittrex.WithdrawalDestination{
+		{Currency: "BTC", Address: "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu"},
+		{Currency: "XMR", Address: "4AfUP827TeRZ1cck3tZThgZbRCEwBrpcJTkA1LCiyFVuMH4b5y59bKMZHGb9y58K3gSjWDCBsB4RkGsGDhsmMG5R2qmbLeW", PaymentID: "my-payment-id"},
+	}
+	maxAmounts := map[string]float64{"btc": 1, "XMR": 5}
+
+	cases := map[string]struct {
+		policy    *bittrex.WithdrawalPolicy
+		currency  string
+		quantity  float64
+		address   string
+		paymentID string
+		exp       bittrex.SubmittedWithdrawal
+		expSent   bool
+		expEvent  bittrex.WithdrawalOutcome
+		wantErr   string
+	}{
+		"sent": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: maxAmounts},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			exp: bittrex.SubmittedWithdrawal{
+				ID:       "68b5a16c-92de-11e3-ba3b-425861b86ab6",
+				Currency: "BTC",
+				Quantity: 0.5,
+				Address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			},
+			expSent:  true,
+			expEvent: bittrex.WithdrawalSent,
+		},
+		"sent with payment id": {
+			policy:    &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: maxAmounts},
+			currency:  "xmr",
+			quantity:  2,
+			address:   allowed[1].Address,
+			paymentID: "my-payment-id",
+			exp: bittrex.SubmittedWithdrawal{
+				ID:        "68b5a16c-92de-11e3-ba3b-425861b86ab6",
+				Currency:  "xmr",
+				Quantity:  2,
+				Address:   allowed[1].Address,
+				PaymentID: "my-payment-id",
+			},
+			expSent:  true,
+			expEvent: bittrex.WithdrawalSent,
+		},
+		"no policy": {
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			wantErr:  "refused: no withdrawal policy is configured",
+		},
+		"address not allowed": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1BoatSLRHtKNngkdXEeobR76b53LETtpyT",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: destination is not in the allowlist",
+		},
+		"currency not allowed for address": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed},
+			currency: "LTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: destination is not in the allowlist",
+		},
+		"payment id missing": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed},
+			currency: "XMR",
+			quantity: 2,
+			address:  allowed[1].Address,
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: destination is not in the allowlist",
+		},
+		"quantity not positive": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed},
+			currency: "BTC",
+			quantity: 0,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: quantity must be positive",
+		},
+		"quantity above maximum": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: map[string]float64{"btc": 0.25}},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: quantity exceeds the maximum of 0.25",
+		},
+		"no maximum": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: map[string]float64{"XMR": 5}},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: no maximum amount is configured for the currency",
+		},
+		"default maximum": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: map[string]float64{"*": 0.25}},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			expEvent: bittrex.WithdrawalRefused,
+			wantErr:  "refused: quantity exceeds the maximum of 0.25",
+		},
+		"dry run": {
+			policy:   &bittrex.WithdrawalPolicy{Allowed: allowed, MaxAmounts: maxAmounts, DryRun: true},
+			currency: "BTC",
+			quantity: 0.5,
+			address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+			exp: bittrex.SubmittedWithdrawal{
+				Currency: "BTC",
+				Quantity: 0.5,
+				Address:  "1DeaaFBdbB5nrHj87x3NHS4onvw1GPNyAu",
+				DryRun:   true,
+			},
+			expEvent: bittrex.WithdrawalSimulated,
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		c := bittrex.New("my-key", "my-secret")
+		c.HTTPClient = ts.Client()
+		c.HostAddr = ts.URL
+		c.WithdrawalPolicy = tc.policy
+
+		var events []bittrex.WithdrawalAuditEvent
+		if tc.policy != nil {
+			tc.policy.AuditHandler = func(e bittrex.WithdrawalAuditEvent) {
+				events = append(events, e)
+			}
+		}
+
+		act, err := c.Withdraw(tc.currency, tc.quantity, tc.address, tc.paymentID)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+			equals(t, id, true, bittrex.IsWithdrawalRefused(err))
+		} else {
+			ok(t, id, err)
+		}
+
+		// Verify whether the withdrawal reached the server.
+		equals(t, id, tc.expSent, rr.Params != nil)
+		if tc.expSent {
+			equals(t, id, tc.currency, rr.Params.Get("currency"))
+			equals(t, id, fmt.Sprintf("%.8f", tc.quantity), rr.Params.Get("quantity"))
+			equals(t, id, tc.address, rr.Params.Get("address"))
+			equals(t, id, tc.paymentID, rr.Params.Get("paymentid"))
+		}
+
+		// The dry run returns the signed request instead of sending it.
+		if tc.exp.DryRun {
+			q := act.Request.URL.Query()
+			equals(t, id, "/api/v1.1/account/withdraw", act.Request.URL.Path)
+			equals(t, id, tc.address, q.Get("address"))
+			equals(t, id, "my-key", q.Get("apiKey"))
+			equals(t, id, 128, len(act.Request.Header.Get("apisign")))
+			act.Request = nil
+		}
+		equals(t, id, tc.exp, act)
+
+		// Verify every attempt was audited.
+		if tc.policy != nil {
+			equals(t, id, 1, len(events))
+			equals(t, id, tc.expEvent, events[0].Outcome)
+			equals(t, id, tc.address, events[0].Address)
+			equals(t, id, tc.exp.ID, events[0].ID)
+			equals(t, id, err, events[0].Err)
+		}
+
+		ts.Close()
+	}
+}
+
+func TestClient_Cancel(t *testing.T) {
+	cases := map[string]struct {
+		client    *bittrex.Client
+		orderUUID string
+		wantErr   string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		err := tc.client.Cancel(tc.orderUUID)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.orderUUID, rr.Params.Get("uuid"))
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_Ticks(t *testing.T) {
+	cases := map[string]struct {
+		client  *bittrex.Client
+		market  string
+		exp     []bittrex.Tick
+		wantErr string
+	}{
+		"normal": {
+			client: bittrex.New("", ""),
+			market: "BTC-WAVES",
+			exp: func() []bittrex.Tick {
+				var result struct {
+					Result []bittrex.Tick `json:"result"`
+				}
+				err := json.Unmarshal(fixturePubGetticks, &result)
+				panicIfErr(err)
+				return result.Result
+			}(),
+		},
+	}
+
+	for id, tc := range cases {
+		ts, _ := bittrex.NewMockRestServer()
+		ts.Start()
+		tc.client.HTTPClient = ts.Client()
+		tc.client.HostAddr = ts.URL
+
+		act, err := tc.client.Ticks(tc.market)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		}
+	}
+}
+
+func TestClient_TicksWithInterval(t *testing.T) {
+	cases := map[string]struct {
+		interval bittrex.TickInterval
+		exp      string
+		wantErr  string
+	}{
+		"one minute": {
+			interval: bittrex.TickOneMin,
+			exp:      "oneMin",
+		},
+		"thirty minutes": {
+			interval: bittrex.TickThirtyMin,
+			exp:      "thirtyMin",
+		},
+		"day": {
+			interval: bittrex.TickDay,
+			exp:      "day",
+		},
+		"invalid interval": {
+			interval: bittrex.TickInterval(42),
+			wantErr:  "invalid tick interval: 42",
+		},
+	}
+
+	for id, tc := range cases {
+		ts, rr := bittrex.NewMockRestServer()
+		ts.Start()
+		c := bittrex.New("", "")
+		c.HTTPClient = ts.Client()
+		c.HostAddr = ts.URL
+
+		act, err := c.TicksWithInterval("BTC-WAVES", tc.interval)
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			ok(t, id, err)
+			equals(t, id, "BTC-WAVES", rr.Params.Get("marketName"))
+			equals(t, id, tc.exp, rr.Params.Get("tickInterval"))
+			equals(t, id, true, len(act) > 0)
+		}
+		ts.Close()
+	}
+}
+
+func TestClient_LatestTick(t *testing.T) {
+	ts, rr := bittrex.NewMockRestServer()
+	ts.Start()
+	defer ts.Close()
+	c := bittrex.New("", "")
+	c.HTTPClient = ts.Client()
+	c.HostAddr = ts.URL
+
+	act, err := c.LatestTick("BTC-WAVES", bittrex.TickHour)
+	ok(t, "latest tick", err)
+	equals(t, "market", "BTC-WAVES", rr.Params.Get("marketName"))
+	equals(t, "interval", "hour", rr.Params.Get("tickInterval"))
+	equals(t, "latest tick", bittrex.Tick{
+		Open:      0.00067460,
+		High:      0.00067625,
+		Low:       0.00067243,
+		Close:     0.00067251,
+		Volume:    731.64231299,
+		Timestamp: "2018-02-12T07:10:00",
+		BV:        0.49260967,
+	}, act)
+}
+
+func TestClient_LiveOrderBook(t *testing.T) {
+	snapshot := map[string]interface{}{
+		"M": nil,
+		"N": 10,
+		"Z": []map[string]float64{{"Q": 1, "R": 0.9}},
+		"S": []map[string]float64{{"Q": 3, "R": 1.1}},
+		"f": []interface{}{},
+	}
+	delta := func(nonce int, rate, quantity float64) map[string]interface{} {
+		return map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"Z": []map[string]float64{{"TY": 0, "R": rate, "Q": quantity}},
+			"S": []interface{}{},
+			"f": []interface{}{},
+		}
+	}
+
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M == "QueryExchangeState" {
+			return mustEncodeC2(snapshot), ""
+		}
+		return true, ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.TrackOrderBooks = true
+	ts.ConfigureClient(c)
+
+	books := make(chan bittrex.OrderBook, 10)
+	c.RegisterOrderBookHandler(func(b bittrex.OrderBook) { books <- b })
+
+	_, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "before subscribing", false, found)
+
+	err := c.Subscribe("BTC-LTC", func(error) {})
+	ok(t, "subscribe", err)
+
+	act, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "seeded", true, found)
+	equals(t, "seeded", []bittrex.OrderBookEntry{{Quantity: 1, Rate: 0.9}}, act.Buy)
+	<-books
+
+	// Push the deltas out of order. They are applied in nonce order.
+	ok(t, "push", ts.PushC2("uE", delta(12, 0.7, 2)))
+	ok(t, "push", ts.PushC2("uE", delta(11, 0.8, 1)))
+
+	// Wait for the update that holds both deltas.
+	for len(act.Buy) < 3 {
+		select {
+		case act = <-books:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the order book update")
+		}
+	}
+
+	exp := bittrex.OrderBook{
+		Market: "BTC-LTC",
+		Buy: []bittrex.OrderBookEntry{
+			{Quantity: 1, Rate: 0.9},
+			{Quantity: 1, Rate: 0.8},
+			{Quantity: 2, Rate: 0.7},
+		},
+		Sell: []bittrex.OrderBookEntry{{Quantity: 3, Rate: 1.1}},
+	}
+	equals(t, "updated", exp, act)
+
+	act, found = c.LiveOrderBook("BTC-LTC")
+	equals(t, "updated", true, found)
+	equals(t, "updated", exp, act)
+
+	// The book is discarded once the market is unsubscribed from.
+	c.Unsubscribe("BTC-LTC")
+	_, found = c.LiveOrderBook("BTC-LTC")
+	equals(t, "unsubscribed", false, found)
+}
+
+func TestClient_LiveOrderBook_Resync(t *testing.T) {
+	// The second snapshot is held back until the test has seen the stale
+	// book.
+	var queries int
+	release := make(chan struct{})
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M != "QueryExchangeState" {
+			return true, ""
+		}
+
+		queries++
+		if queries == 1 {
+			return mustEncodeC2(map[string]interface{}{
+				"N": 10,
+				"Z": []map[string]float64{{"Q": 1, "R": 0.9}},
+				"S": []interface{}{},
+			}), ""
+		}
+
+		<-release
+		return mustEncodeC2(map[string]interface{}{
+			"N": 12,
+			"Z": []map[string]float64{{"Q": 5, "R": 0.5}},
+			"S": []interface{}{},
+		}), ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.TrackOrderBooks = true
+	c.NonceGapTimeout = 50 * time.Millisecond
+	ts.ConfigureClient(c)
+
+	events := make(chan bittrex.StreamEvent, 10)
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) { events <- e })
+
+	nextEvent := func() bittrex.StreamEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a stream event")
+		}
+		return bittrex.StreamEvent{}
+	}
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// Skip nonce 11. The book is resynced once the gap times out.
+	ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+		"M": "BTC-LTC",
+		"N": 12,
+		"Z": []map[string]float64{{"TY": 0, "R": 0.7, "Q": 2}},
+	}))
+
+	e := nextEvent()
+	equals(t, "started", bittrex.ResyncStarted, e.Type)
+	equals(t, "started", "BTC-LTC", e.Market)
+	errMatches(t, "started", e.Err, "exchange update 11 of BTC-LTC is missing")
+
+	_, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "stale", false, found)
+
+	close(release)
+	e = nextEvent()
+	equals(t, "completed", bittrex.ResyncCompleted, e.Type)
+
+	// The buffered update is already part of the new snapshot.
+	act, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "resynced", true, found)
+	equals(t, "resynced", []bittrex.OrderBookEntry{{Quantity: 5, Rate: 0.5}}, act.Buy)
+}
+
+func TestClient_Subscriptions(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	trades := make(chan bittrex.Trade, 10)
+	c.Register(func(t bittrex.Trade) { trades <- t })
+
+	// A subscription that fails is forgotten.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errMatches(t, "canceled", c.SubscribeContext(ctx, "BTC-LTC", func(error) {}), "context canceled")
+	equals(t, "canceled", []string{}, c.Subscriptions())
+
+	// Duplicate subscriptions are only sent once.
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+	equals(t, "subscribed", []string{"BTC-ETH", "BTC-LTC"}, c.Subscriptions())
+
+	var sent []string
+	eventually(t, "subscribed", func() bool {
+		sent = nil
+		for _, m := range ts.Invocations() {
+			if m.M == "SubscribeToExchangeDeltas" {
+				sent = append(sent, m.A[0].(string))
+			}
+		}
+		return len(sent) >= 2
+	})
+	equals(t, "subscribed", []string{"BTC-LTC", "BTC-ETH"}, sent)
+
+	// Trades of unsubscribed markets are no longer dispatched, even though the
+	// server keeps sending them.
+	c.Unsubscribe("BTC-LTC")
+	equals(t, "unsubscribed", []string{"BTC-ETH"}, c.Subscriptions())
+
+	fill := func(market string) map[string]interface{} {
+		return map[string]interface{}{
+			"M": market,
+			"N": 1,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}
+	}
+	ok(t, "push", ts.PushC2("uE", fill("BTC-LTC")))
+	ok(t, "push", ts.PushC2("uE", fill("BTC-ETH")))
+
+	select {
+	case tr := <-trades:
+		equals(t, "trade", "BTC-ETH", tr.Market())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the trade")
+	}
+
+	select {
+	case tr := <-trades:
+		t.Errorf("unexpected trade: %v", tr)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestClient_Subscribe_Concurrent(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M != "SubscribeToExchangeDeltas" {
+			return true, ""
+		}
+		started <- struct{}{}
+		<-release
+		return nil, "market is closed"
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	// The second call waits for the subscription that the first one is
+	// sending, and fails along with it.
+	errs := make(chan error, 2)
+	go func() { errs <- c.Subscribe("BTC-LTC", func(error) {}) }()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscription")
+	}
+	go func() { errs <- c.Subscribe("BTC-LTC", func(error) {}) }()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("subscribe returned before the hub answered: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			equals(t, "hub error", true, bittrex.IsHubError(err))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for subscribe to return")
+		}
+	}
+	equals(t, "subscriptions", []string{}, c.Subscriptions())
+
+	sent := 0
+	for _, m := range ts.Invocations() {
+		if m.M == "SubscribeToExchangeDeltas" {
+			sent++
+		}
+	}
+	equals(t, "sent", 1, sent)
+}
+
+func TestClient_SubscribeSummaryDeltas_Concurrent(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M != "SubscribeToSummaryDeltas" {
+			return true, ""
+		}
+		started <- struct{}{}
+		<-release
+		return nil, "summaries are unavailable"
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	// The second call waits for the subscription that the first one is
+	// sending, and fails along with it.
+	errs := make(chan error, 2)
+	go func() { errs <- c.SubscribeSummaryDeltas(func(error) {}) }()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the subscription")
+	}
+	go func() { errs <- c.SubscribeSummaryDeltas(func(error) {}) }()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("subscribe returned before the hub answered: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			equals(t, "hub error", true, bittrex.IsHubError(err))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for subscribe to return")
+		}
+	}
+
+	sent := 0
+	for _, m := range ts.Invocations() {
+		if m.M == "SubscribeToSummaryDeltas" {
+			sent++
+		}
+	}
+	equals(t, "sent", 1, sent)
+}
+
+func TestClient_BadMessages(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	trades := make(chan bittrex.Trade, 10)
+	c.Register(func(t bittrex.Trade) { trades <- t })
+	errs := make(chan error, 10)
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(err error) { errs <- err }))
+
+	// Messages that can't be processed are reported and skipped.
+	ok(t, "push", ts.Push("C2", "uE", "not base64!"))
+	ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+		"M": "BTC-LTC",
+		"N": 1,
+		"f": []map[string]interface{}{
+			{"OT": "HOLD", "R": 0.5, "Q": 1, "T": 1516456455310},
+			{"OT": "SELL", "R": 0.6, "Q": 1, "T": 1516456455310},
+		},
+	}))
+
+	var reported []error
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			reported = append(reported, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the error")
+		}
+	}
+	sort.Slice(reported, func(i, j int) bool { return reported[i].Error() < reported[j].Error() })
+	errMatches(t, "corrupt", reported[0], "failed to decode the exchange deltas")
+	errMatches(t, "invalid fill", reported[1], "invalid trade type: HOLD")
+
+	// The valid fills still get through.
+	select {
+	case tr := <-trades:
+		equals(t, "trade", 0.6, tr.Price)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the trade")
+	}
+
+	// The messages keep being processed, including the hub responses.
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+	ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+		"M": "BTC-ETH",
+		"N": 1,
+		"f": []map[string]interface{}{
+			{"OT": "BUY", "R": 0.7, "Q": 1, "T": 1516456455310},
+		},
+	}))
+	select {
+	case tr := <-trades:
+		equals(t, "trade", "BTC-ETH", tr.Market())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the trade")
+	}
+}
+
+func TestClient_OrderedDelivery(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	c.QueueSize = 10
+	c.NonceGapTimeout = 200 * time.Millisecond
+	ts.ConfigureClient(c)
+
+	trades := make(chan bittrex.Trade, 10)
+	c.Register(func(t bittrex.Trade) { trades <- t })
+	gaps := make(chan bittrex.StreamEvent, 10)
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) {
+		if e.Type == bittrex.NonceGap {
+			gaps <- e
+		}
+	})
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// The rate of each trade is the nonce of its exchange update.
+	push := func(nonce int) {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": nonce, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+	next := func() float64 {
+		select {
+		case tr := <-trades:
+			return tr.Price
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the trade")
+			return 0
+		}
+	}
+
+	// The first update starts the sequence.
+	push(1)
+	equals(t, "first", 1.0, next())
+
+	// Updates that arrive out of order are put back in order.
+	push(3)
+	push(2)
+	push(4)
+	equals(t, "ordered", []float64{2, 3, 4}, []float64{next(), next(), next()})
+
+	// A missing update is given up on after a while, which is reported.
+	push(6)
+	equals(t, "gap", 6.0, next())
+	select {
+	case e := <-gaps:
+		equals(t, "gap event", "BTC-LTC", e.Market)
+		equals(t, "gap event", &bittrex.NonceGapError{Market: "BTC-LTC", First: 5, Last: 5}, e.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the gap event")
+	}
+
+	stats := c.QueueStats()
+	equals(t, "stats", 1, len(stats))
+	equals(t, "stats", "BTC-LTC", stats[0].Market)
+	equals(t, "stats", 10, stats[0].Capacity)
+	equals(t, "stats", uint64(0), stats[0].Dropped)
+	eventually(t, "stats", func() bool { return c.QueueStats()[0].Delivered == 5 })
+}
+
+func TestClient_OrderedDelivery_Reversed(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	ts.ConfigureClient(c)
+
+	trades := make(chan bittrex.Trade, 10)
+	c.Register(func(t bittrex.Trade) { trades <- t })
+	gaps := make(chan bittrex.StreamEvent, 10)
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) {
+		if e.Type == bittrex.NonceGap {
+			gaps <- e
+		}
+	})
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// The rate of each trade is the nonce of its exchange update.
+	push := func(nonce int) {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": nonce, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+	next := func() float64 {
+		select {
+		case tr := <-trades:
+			return tr.Price
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the trade")
+			return 0
+		}
+	}
+
+	// The sequence starts with the earliest update that arrives in the first
+	// moments, whatever the order.
+	push(3)
+	push(2)
+	equals(t, "started", []float64{2, 3}, []float64{next(), next()})
+
+	// An update that arrives after the sequence has moved on past it is late.
+	// It is reported rather than delivered out of order.
+	push(1)
+	select {
+	case e := <-gaps:
+		equals(t, "late", &bittrex.NonceGapError{Market: "BTC-LTC", First: 1, Last: 1, Late: true}, e.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the gap event")
+	}
+	push(4)
+	equals(t, "next", 4.0, next())
+}
+
+func TestClient_RegisterStreamEventHandler(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	c.NonceGapTimeout = 50 * time.Millisecond
+	ts.ConfigureClient(c)
+
+	c.Register(func(bittrex.Trade) {})
+	kept := make(chan bittrex.StreamEvent, 10)
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) { kept <- e })
+	removed := make(chan bittrex.StreamEvent, 10)
+	reg := c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) { removed <- e })
+	reg.Unregister()
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// Skip nonce 2, which is reported once the gap times out.
+	for _, nonce := range []int{1, 3} {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+
+	select {
+	case e := <-kept:
+		equals(t, "kept", bittrex.NonceGap, e.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream event")
+	}
+	time.Sleep(50 * time.Millisecond)
+	equals(t, "removed", 0, len(removed))
+}
+
+func TestClient_OrderedDelivery_Unsubscribe(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	c.QueueSize = 1
+	c.QueueOverflow = bittrex.OverflowBlock
+	ts.ConfigureClient(c)
+
+	// The handler blocks on the first trade until the queue is full and then
+	// unsubscribes, while the next trade waits for room in the queue.
+	first := make(chan struct{})
+	proceed := make(chan struct{})
+	unsubscribed := make(chan struct{})
+	var once sync.Once
+	c.Register(func(t bittrex.Trade) {
+		once.Do(func() {
+			close(first)
+			<-proceed
+			c.Unsubscribe("BTC-LTC")
+			close(unsubscribed)
+		})
+	})
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	push := func(nonce int) {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+
+	push(1)
+	select {
+	case <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the first trade")
+	}
+	push(2)
+	push(3)
+	eventually(t, "full", func() bool {
+		stats := c.QueueStats()
+		return len(stats) == 1 && stats[0].Depth == 1
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	close(proceed)
+	select {
+	case <-unsubscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unsubscribing from the handler deadlocked")
+	}
+	equals(t, "subscriptions", []string{}, c.Subscriptions())
+}
+
+func TestClient_OrderedDelivery_Blocked(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	c.QueueSize = 1
+	c.QueueOverflow = bittrex.OverflowBlock
+	c.InvocationTimeout = 2 * time.Second
+	ts.ConfigureClient(c)
+
+	// The handler blocks until the end of the test, so the queue fills up and
+	// the processing of the market data is held up.
+	release := make(chan struct{})
+	defer close(release)
+	c.Register(func(t bittrex.Trade) { <-release })
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	for nonce := 1; nonce <= 3; nonce++ {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+	eventually(t, "full", func() bool {
+		stats := c.QueueStats()
+		return len(stats) == 1 && stats[0].Depth == 1
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	// The responses to hub invocations still get through.
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+}
+
+func TestClient_MessageBufferSize(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.OrderedDelivery = true
+	c.QueueSize = 1
+	c.QueueOverflow = bittrex.OverflowBlock
+	c.MessageBufferSize = 1
+	c.InvocationTimeout = 200 * time.Millisecond
+	ts.ConfigureClient(c)
+
+	// The handler blocks on the first trade, so the queue and then the
+	// message buffer fill up with the ones that follow.
+	release := make(chan struct{})
+	c.Register(func(t bittrex.Trade) { <-release })
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	push := func(nonce int) {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": "BTC-LTC",
+			"N": nonce,
+			"f": []map[string]interface{}{
+				{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+	push(1)
+	eventually(t, "started", func() bool { return len(c.QueueStats()) == 1 })
+	for nonce := 2; nonce <= 6; nonce++ {
+		push(nonce)
+	}
+	eventually(t, "full", func() bool {
+		stats := c.QueueStats()
+		return len(stats) == 1 && stats[0].Depth == 1
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	// Nothing more is read from the connection, so the response doesn't get
+	// through.
+	errMatches(t, "stalled", c.Subscribe("BTC-ETH", func(error) {}), "context deadline exceeded")
+
+	// Once the handler catches up, the connection is read from again.
+	close(release)
+	c.InvocationTimeout = 5 * time.Second
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+}
+
+func TestClient_Register(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	ethTrades := make(chan bittrex.Trade, 10)
+	allTrades := make(chan bittrex.Trade, 10)
+	c.Register(func(t bittrex.Trade) { ethTrades <- t }, "BTC-ETH")
+	all := c.Register(func(t bittrex.Trade) { allTrades <- t })
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+
+	push := func(nonce int) {
+		for _, market := range []string{"BTC-LTC", "BTC-ETH"} {
+			ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+				"M": market,
+				"N": nonce,
+				"f": []map[string]interface{}{
+					{"OT": "BUY", "R": 0.5, "Q": 1, "T": 1516456455310},
+				},
+			}))
+		}
+	}
+	markets := func(trades chan bittrex.Trade, n int) []string {
+		var ms []string
+		for i := 0; i < n; i++ {
+			select {
+			case tr := <-trades:
+				ms = append(ms, tr.Market())
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for a trade")
+			}
+		}
+		sort.Strings(ms)
+		return ms
+	}
+
+	// The filtered handler only gets the trades of its market.
+	push(1)
+	equals(t, "filtered", []string{"BTC-ETH"}, markets(ethTrades, 1))
+	equals(t, "all", []string{"BTC-ETH", "BTC-LTC"}, markets(allTrades, 2))
+
+	// An unregistered handler gets nothing.
+	all.Unregister()
+	all.Unregister()
+	push(2)
+	equals(t, "filtered", []string{"BTC-ETH"}, markets(ethTrades, 1))
+	select {
+	case tr := <-allTrades:
+		t.Errorf("unexpected trade: %v", tr)
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case tr := <-ethTrades:
+		t.Errorf("unexpected trade: %v", tr)
+	default:
+	}
+}
+
+func TestClient_Trades(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trades, err := c.Trades(ctx, "BTC-ETH")
+	ok(t, "trades", err)
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+
+	// Only the trades of the requested markets are received.
+	for _, market := range []string{"BTC-LTC", "BTC-ETH"} {
+		ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+			"M": market,
+			"N": 1,
+			"f": []map[string]interface{}{
+				{"OT": "SELL", "R": 0.5, "Q": 1, "T": 1516456455310},
+			},
+		}))
+	}
+
+	select {
+	case tr := <-trades:
+		equals(t, "trade", "BTC-ETH", tr.Market())
+		equals(t, "trade", bittrex.SellType, tr.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the trade")
+	}
+
+	// Canceling the context closes the channel and unregisters the stream.
+	cancel()
+	eventually(t, "canceled", func() bool {
+		select {
+		case _, open := <-trades:
+			return !open
+		default:
+			return false
+		}
+	})
+	eventually(t, "unregistered", func() bool { return len(c.QueueStats()) == 0 })
+
+	// No streams are started on behalf of a caller that is no longer
+	// interested.
+	_, err = c.Trades(ctx)
+	errMatches(t, "canceled", err, "stream not started: context canceled")
+}
+
+func TestClient_OrderBooks(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M == "QueryExchangeState" {
+			return mustEncodeC2(map[string]interface{}{
+				"N": 10,
+				"Z": []map[string]interface{}{{"Q": 1, "R": 0.5}},
+				"S": []interface{}{},
+			}), ""
+		}
+		return true, ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	_, err := c.OrderBooks(context.Background())
+	errMatches(t, "untracked", err, "order books are not tracked")
+
+	c.TrackOrderBooks = true
+	books, err := c.OrderBooks(context.Background(), "BTC-LTC")
+	ok(t, "books", err)
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	select {
+	case ob := <-books:
+		equals(t, "book", "BTC-LTC", ob.Market)
+		equals(t, "book", []bittrex.OrderBookEntry{{Quantity: 1, Rate: 0.5}}, ob.Buy)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the book")
+	}
+
+	// Closing the client closes the channel.
+	ok(t, "close", c.Close(context.Background()))
+	eventually(t, "closed", func() bool {
+		select {
+		case _, open := <-books:
+			return !open
+		default:
+			return false
+		}
+	})
+}
+
+func TestClient_ProcessCandles(t *testing.T) {
+	cases := map[string]struct {
+		interval time.Duration
+		closed   bool
+		wantErr  string
+	}{
+		"valid interval": {
+			interval: time.Minute,
+		},
+		"invalid interval": {
+			interval: 0,
+			wantErr:  "failed to process candles: invalid interval: 0s",
+		},
+		"closed client": {
+			interval: time.Minute,
+			closed:   true,
+			wantErr:  "client is closed",
+		},
+	}
+
+	for id, tc := range cases {
+		c := bittrex.New("", "")
+		if tc.closed {
+			ok(t, id, c.Close(context.Background()))
+		}
+
+		err := c.ProcessCandles(tc.interval, func(bittrex.Candle) {})
+		if tc.wantErr != "" {
+			errMatches(t, id, err, tc.wantErr)
+		} else {
+			ok(t, id, err)
+		}
+		ok(t, id, c.Close(context.Background()))
+	}
+}
+
+func TestClient_Candles(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.CandleGracePeriod = 0
+	ts.ConfigureClient(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	candles, err := c.Candles(ctx, 100*time.Millisecond, "BTC-LTC")
+	ok(t, "candles", err)
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// Trades of candles that are already finalized are dropped, so these
+	// happen shortly after the test pushes them.
+	start := time.Now().UTC().Add(300 * time.Millisecond).Truncate(100 * time.Millisecond)
+	ms := func(d time.Duration) int64 { return start.Add(d).UnixNano() / int64(time.Millisecond) }
+	ok(t, "push", ts.PushC2("uE", map[string]interface{}{
+		"M": "BTC-LTC",
+		"N": 1,
+		"f": []map[string]interface{}{
+			{"OT": "BUY", "R": 0.5, "Q": 1, "T": ms(10 * time.Millisecond)},
+			{"OT": "SELL", "R": 0.75, "Q": 3, "T": ms(80 * time.Millisecond)},
+			{"OT": "SELL", "R": 0.625, "Q": 4, "T": ms(150 * time.Millisecond)},
+		},
+	}))
+
+	// The trades are bucketed by their own time, on multiples of the interval.
+	for i, exp := range []bittrex.Candle{
+		{
+			Market:     "BTC-LTC",
+			Time:       start,
+			Open:       0.5,
+			High:       0.75,
+			Low:        0.5,
+			Close:      0.75,
+			Volume:     4,
+			BuyVolume:  1,
+			SellVolume: 3,
+			BaseVolume: 2.75,
+			VWAP:       0.6875,
+			Trades:     2,
+		},
+		{
+			Market:     "BTC-LTC",
+			Time:       start.Add(100 * time.Millisecond),
+			Open:       0.625,
+			High:       0.625,
+			Low:        0.625,
+			Close:      0.625,
+			Volume:     4,
+			SellVolume: 4,
+			BaseVolume: 2.5,
+			VWAP:       0.625,
+			Trades:     1,
+		},
+	} {
+		select {
+		case candle := <-candles:
+			equals(t, fmt.Sprintf("candle %d", i), exp, candle)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the candle")
+		}
+	}
+
+	cancel()
+	eventually(t, "canceled", func() bool {
+		select {
+		case _, open := <-candles:
+			return !open
+		default:
+			return false
+		}
+	})
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M == "QueryExchangeState" {
+			return mustEncodeC2(map[string]interface{}{"N": 10, "Z": []interface{}{}, "S": []interface{}{}}), ""
+		}
+		return true, ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.TrackOrderBooks = true
+	ts.ConfigureClient(c)
+
+	var events []bittrex.StreamEvent
+	var eventsMux sync.Mutex
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) {
+		eventsMux.Lock()
+		events = append(events, e)
+		eventsMux.Unlock()
+	})
+	hasEvent := func(typ bittrex.StreamEventType, market string) bool {
+		eventsMux.Lock()
+		defer eventsMux.Unlock()
+		for _, e := range events {
+			if e.Type == typ && e.Market == market {
+				return true
+			}
+		}
+		return false
+	}
+	count := func(method string) int {
+		var n int
+		for _, m := range ts.Invocations() {
+			if m.M == method {
+				n++
+			}
+		}
+		return n
+	}
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+	ok(t, "subscribe", c.Subscribe("BTC-ETH", func(error) {}))
+	eventually(t, "subscribed", func() bool { return count("SubscribeToExchangeDeltas") == 2 })
+
+	ts.DropConnections()
+
+	// Every subscription is sent again and every book is seeded again.
+	eventually(t, "reconnected", func() bool { return hasEvent(bittrex.Reconnected, "") })
+	eventually(t, "resubscribed", func() bool {
+		return hasEvent(bittrex.Resubscribed, "BTC-LTC") && hasEvent(bittrex.Resubscribed, "BTC-ETH")
+	})
+	eventually(t, "resynced", func() bool {
+		return hasEvent(bittrex.ResyncCompleted, "BTC-LTC") && hasEvent(bittrex.ResyncCompleted, "BTC-ETH")
+	})
+	equals(t, "resubscribed", 4, count("SubscribeToExchangeDeltas"))
+	equals(t, "resynced", 4, count("QueryExchangeState"))
+	equals(t, "connections", 1, ts.Connections())
+
+	_, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "resynced", true, found)
+}
+
+func TestClient_Authenticate(t *testing.T) {
+	mac := hmac.New(sha512.New, []byte("secret"))
+	_, _ = mac.Write([]byte("challenge"))
+	signed := hex.EncodeToString(mac.Sum(nil))
+
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		switch m.M {
+		case "GetAuthContext":
+			return "challenge", ""
+		case "Authenticate":
+			return len(m.A) == 2 && m.A[0] == "key" && m.A[1] == signed, ""
+		}
+		return true, ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("key", "secret")
+	ts.ConfigureClient(c)
+
+	var events []bittrex.StreamEvent
+	var eventsMux sync.Mutex
+	c.RegisterStreamEventHandler(func(e bittrex.StreamEvent) {
+		eventsMux.Lock()
+		events = append(events, e)
+		eventsMux.Unlock()
+	})
+	reauthenticated := func() int {
+		eventsMux.Lock()
+		defer eventsMux.Unlock()
+		var n int
+		for _, e := range events {
+			if e.Type == bittrex.Reauthenticated {
+				n++
+			}
+		}
+		return n
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	orders, err := c.OrderDeltas(ctx)
+	ok(t, "order deltas", err)
+
+	balances := make(chan bittrex.BalanceDelta, 1)
+	reg := c.RegisterBalanceDeltaHandler(func(d bittrex.BalanceDelta) { balances <- d })
+	defer reg.Unregister()
+
+	ok(t, "authenticate", c.Authenticate(func(error) {}))
+
+	ok(t, "push", ts.PushC2("uO", map[string]interface{}{
+		"w": "acct", "N": 3, "TY": 2,
+		"o": map[string]interface{}{
+			"OU": "o1", "E": "BTC-LTC", "OT": "LIMIT_BUY", "Q": 5, "q": 0, "X": 0.00002,
+			"PU": 0.00002, "Y": 1516456455300, "C": 1516456455310, "u": 1516456455310,
+		},
+	}))
+	select {
+	case d := <-orders:
+		equals(t, "order nonce", uint(3), d.Nonce)
+		equals(t, "order type", bittrex.OrderFilled, d.Type)
+		equals(t, "order uuid", bittrex.OrderID("o1"), d.Order.OrderUUID)
+		equals(t, "order price per unit", 0.00002, d.Order.PricePerUnit)
+		equals(t, "order closed", time.Date(2018, 1, 20, 13, 54, 15, 310000000, time.UTC), *d.Order.Closed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the order delta")
+	}
+
+	ok(t, "push", ts.PushC2("uB", map[string]interface{}{
+		"N": 4,
+		"d": map[string]interface{}{"c": "BTC", "b": 1.5, "a": 1.25, "z": 0.25, "p": "addr", "u": 1516456455310},
+	}))
+	select {
+	case d := <-balances:
+		equals(t, "balance", bittrex.Balance{
+			Currency:      "BTC",
+			Balance:       1.5,
+			Available:     1.25,
+			Pending:       0.25,
+			CryptoAddress: "addr",
+		}, d.Balance)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the balance delta")
+	}
+
+	// The connection is authenticated again after a reconnect and before the
+	// authentication expires.
+	ts.DropConnections()
+	eventually(t, "reconnected", func() bool { return reauthenticated() == 1 })
+	ok(t, "push", ts.Push("C2", "authenticationExpiring"))
+	eventually(t, "expiring", func() bool { return reauthenticated() == 2 })
+
+	// A wrong secret is rejected.
+	c2 := bittrex.New("key", "wrong")
+	ts.ConfigureClient(c2)
+	errMatches(t, "rejected", c2.Authenticate(func(error) {}), "authentication was rejected")
+}
+
+func TestClient_SummaryDeltas(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(nil)
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	ts.ConfigureClient(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	summaries, err := c.MarketSummaryUpdates(ctx)
+	ok(t, "summaries", err)
+
+	handled := make(chan bittrex.MarketSummary, 10)
+	reg := c.RegisterMarketSummaryHandler(func(s bittrex.MarketSummary) { handled <- s }, "BTC-LTC")
+	defer reg.Unregister()
+
+	ok(t, "subscribe", c.SubscribeSummaryDeltas(func(error) {}))
+	ok(t, "subscribe", c.SubscribeSummaryLiteDeltas(func(error) {}))
+	ok(t, "subscribe", c.SubscribeSummaryDeltas(func(error) {}))
+	eventually(t, "subscribed", func() bool { return len(ts.Invocations()) == 2 })
+	equals(t, "methods", "SubscribeToSummaryDeltas", ts.Invocations()[0].M)
+	equals(t, "methods", "SubscribeToSummaryLiteDeltas", ts.Invocations()[1].M)
+
+	receive := func(id string) bittrex.MarketSummary {
+		select {
+		case s := <-summaries:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for the summary", id)
+			return bittrex.MarketSummary{}
+		}
+	}
+
+	ok(t, "push", ts.PushC2("uS", map[string]interface{}{
+		"N": 2,
+		"D": []map[string]interface{}{
+			{"M": "BTC-LTC", "H": 0.02, "L": 0.01, "V": 100, "l": 0.015, "m": 1.5, "T": 1516456455310,
+				"B": 0.014, "A": 0.016, "G": 12, "g": 34, "PD": 0.012, "x": 1399912345000},
+		},
+	}))
+	s := receive("full")
+	equals(t, "full", bittrex.MarketSummary{
+		MarketName:     "BTC-LTC",
+		High:           0.02,
+		Low:            0.01,
+		Volume:         100,
+		Last:           0.015,
+		BaseVolume:     1.5,
+		TimeStamp:      "2018-01-20T13:54:15.31",
+		Bid:            0.014,
+		Ask:            0.016,
+		OpenBuyOrders:  12,
+		OpenSellOrders: 34,
+		PrevDay:        0.012,
+		Created:        "2014-05-12T16:32:25",
+	}, s)
+	at, err := s.Time()
+	ok(t, "time", err)
+	equals(t, "time", time.Date(2018, 1, 20, 13, 54, 15, 310000000, time.UTC), at)
+
+	// Stale summaries are ignored, but a late message can still hold the
+	// latest summaries of other markets.
+	ok(t, "push", ts.PushC2("uS", map[string]interface{}{
+		"N": 1,
+		"D": []map[string]interface{}{{"M": "BTC-LTC", "l": 0.001}, {"M": "BTC-DOGE", "l": 0.0000005}},
+	}))
+	late := receive("late")
+	equals(t, "late", "BTC-DOGE", late.MarketName)
+	equals(t, "late", 0.0000005, late.Last)
+
+	// Lite deltas are merged into the latest full summary.
+	ok(t, "push", ts.PushC2("uL", map[string]interface{}{
+		"N": 7,
+		"D": []map[string]interface{}{{"M": "BTC-LTC", "l": 0.017, "m": 1.6}, {"M": "BTC-ETH", "l": 0.1, "m": 9}},
+	}))
+	merged := map[string]bittrex.MarketSummary{}
+	for len(merged) < 2 {
+		s := receive("lite")
+		merged[s.MarketName] = s
+	}
+	equals(t, "merged", 0.017, merged["BTC-LTC"].Last)
+	equals(t, "merged", 1.6, merged["BTC-LTC"].BaseVolume)
+	equals(t, "merged", 0.016, merged["BTC-LTC"].Ask)
+	equals(t, "new", bittrex.MarketSummary{MarketName: "BTC-ETH", Last: 0.1, BaseVolume: 9}, merged["BTC-ETH"])
+
+	// The handler only gets the summaries of its market.
+	eventually(t, "handled", func() bool { return len(handled) == 2 })
+	for len(handled) > 0 {
+		equals(t, "handled", "BTC-LTC", (<-handled).MarketName)
+	}
+
+	// The summary subscriptions are sent again after a reconnect.
+	ts.DropConnections()
+	eventually(t, "resubscribed", func() bool { return len(ts.Invocations()) == 4 })
+}
+
+func TestClient_Close(t *testing.T) {
+	ts := bittrex.NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M == "QueryExchangeState" {
+			return mustEncodeC2(map[string]interface{}{"N": 10, "Z": []interface{}{}, "S": []interface{}{}}), ""
+		}
+		return true, ""
+	})
+	defer ts.Close()
+
+	c := bittrex.New("", "")
+	c.TrackOrderBooks = true
+	ts.ConfigureClient(c)
+	ok(t, "candles", c.ProcessCandles(time.Millisecond, func(bittrex.Candle) {}))
+
+	var errs []error
+	var errsMux sync.Mutex
+	errHandler := func(err error) {
+		errsMux.Lock()
+		errs = append(errs, err)
+		errsMux.Unlock()
+	}
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", errHandler))
+	equals(t, "connections", 1, ts.Connections())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok(t, "close", c.Close(ctx))
+	ok(t, "close again", c.Close(ctx))
+
+	// The connection is gone and the websocket side can't be used anymore.
+	eventually(t, "disconnected", func() bool { return ts.Connections() == 0 })
+	_, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "book", false, found)
+	errMatches(t, "subscribe", c.Subscribe("BTC-ETH", errHandler), "client is closed")
+
+	// Closing the connection isn't reported as an error.
+	errsMux.Lock()
+	equals(t, "errors", 0, len(errs))
+	errsMux.Unlock()
+
+	// A client that was never started closes right away.
+	ok(t, "unstarted", bittrex.New("", "").Close(ctx))
+}
+
+func TestClient_Context(t *testing.T) {
+	cases := map[string]struct {
+		call func(ctx context.Context, c *bittrex.Client) error
+	}{
+		"markets": {
+			call: func(ctx context.Context, c *bittrex.Client) error { _, err := c.MarketsContext(ctx); return err },
+		},
+		"balances": {
+			call: func(ctx context.Context, c *bittrex.Client) error { _, err := c.BalancesContext(ctx); return err },
+		},
+		"order history": {
+			call: func(ctx context.Context, c *bittrex.Client) error { _, err := c.OrderHistoryContext(ctx); return err },
+		},
+		"open orders": {
+			call: func(ctx context.Context, c *bittrex.Client) error { _, err := c.OpenOrdersContext(ctx, ""); return err },
+		},
+		"order": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.OrderContext(ctx, "my-uuid")
+				return err
+			},
+		},
+		"balance": {
+			call: func(ctx context.Context, c *bittrex.Client) error { _, err := c.BalanceContext(ctx, "BTC"); return err },
+		},
+		"deposit address": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.DepositAddressContext(ctx, "BTC")
+				return err
+			},
+		},
+		"deposit history": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.DepositHistoryContext(ctx, "")
+				return err
+			},
+		},
+		"withdrawal history": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.WithdrawalHistoryContext(ctx, "")
+				return err
+			},
+		},
+		"limit buy": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.LimitBuyContext(ctx, "BTC-ETH", 1, 1)
+				return err
+			},
+		},
+		"limit sell": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.LimitSellContext(ctx, "BTC-ETH", 1, 1)
+				return err
+			},
+		},
+		"cancel": {
+			call: func(ctx context.Context, c *bittrex.Client) error { return c.CancelContext(ctx, "my-uuid") },
+		},
+		"ticks": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				_, err := c.TicksContext(ctx, "BTC-WAVES")
+				return err
+			},
+		},
+		"subscribe": {
+			call: func(ctx context.Context, c *bittrex.Client) error {
+				return c.SubscribeContext(ctx, "BTC-WAVES", func(error) {})
+			},
+		},
+	}
+
+	for id, tc := range cases {
+		ts, _ := bittrex.NewMockRestServer()
+		ts.Start()
+		c := bittrex.New("", "")
+		c.HTTPClient = ts.Client()
+		c.HostAddr = ts.URL
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := tc.call(ctx, c)
+		errMatches(t, id, err, "context canceled")
+
+		ts.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package bittrex
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/carterjones/signalr"
+	"github.com/pkg/errors"
+)
+
+// Close stops the websocket side of the client. The connection of the
+// underlying SignalR client is closed, messages that are still in flight are
+// discarded, candle processing stops and the local order books are dropped.
+// Close then waits for the goroutines started by the client to exit. If the
+// context is done first, its error is returned and the goroutines finish
+// exiting in the background.
+//
+// The REST methods keep working after Close, but nothing can be subscribed to
+// anymore. Calling Close more than once is safe.
+func (c *Client) Close(ctx context.Context) error {
+	c.lifeMux.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.closingLocked())
+	}
+	c.lifeMux.Unlock()
+
+	// Stop the gap timers so that they don't start any resyncs or release any
+	// trades.
+	c.booksMux.Lock()
+	for _, b := range c.books {
+		if b.gapTimer != nil {
+			b.gapTimer.Stop()
+		}
+	}
+	c.books = nil
+	c.booksMux.Unlock()
+
+	c.sequencesMux.Lock()
+	for _, s := range c.sequences {
+		if s.gapTimer != nil {
+			s.gapTimer.Stop()
+		}
+	}
+	c.sequences = nil
+	c.sequencesMux.Unlock()
+
+	// Closing the connection ends the read loop of the SignalR client without
+	// it trying to reconnect.
+	c.connMux.Lock()
+	conn := c.conn
+	c.connMux.Unlock()
+	closeConn(conn)
+
+	exited := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "gave up waiting for the client to stop")
+	}
+}
+
+// closing returns a channel that is closed once the client is closed.
+func (c *Client) closing() <-chan struct{} {
+	c.lifeMux.Lock()
+	defer c.lifeMux.Unlock()
+	return c.closingLocked()
+}
+
+// closingLocked is like closing, but the caller must hold lifeMux.
+func (c *Client) closingLocked() chan struct{} {
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	return c.done
+}
+
+// isClosed indicates if Close has been called.
+func (c *Client) isClosed() bool {
+	c.lifeMux.Lock()
+	defer c.lifeMux.Unlock()
+	return c.closed
+}
+
+// spawn runs f in a goroutine that Close waits for. It reports false, and
+// doesn't run f, if the client is already closed.
+func (c *Client) spawn(f func()) bool {
+	c.lifeMux.Lock()
+	defer c.lifeMux.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		f()
+	}()
+
+	return true
+}
+
+// lifetimeContext returns a context with the specified timeout that is also
+// canceled once the client is closed.
+func (c *Client) lifetimeContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	done := c.closing()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// setConn records the current connection of the underlying SignalR client. If
+// the client was closed in the meantime, the connection is closed right away.
+func (c *Client) setConn(conn signalr.WebsocketConn) {
+	c.connMux.Lock()
+	c.conn = conn
+	c.connMux.Unlock()
+
+	if c.isClosed() {
+		closeConn(conn)
+	}
+}
+
+// closeConn closes the connection, if it can be closed.
+func closeConn(conn signalr.WebsocketConn) {
+	if cl, ok := conn.(io.Closer); ok {
+		_ = cl.Close()
+	}
+}
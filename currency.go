@@ -0,0 +1,18 @@
+package bittrex
+
+// Currency represents a currency that is supported by Bittrex.
+type Currency struct {
+	Currency        string
+	CurrencyLong    string
+	MinConfirmation int
+	TxFee           float64
+	IsActive        bool
+	IsRestricted    bool
+	CoinType        string
+	BaseAddress     *string
+	Notice          *string
+}
+
+func (c Currency) String() string {
+	return c.Currency
+}
@@ -0,0 +1,47 @@
+package bittrex
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DepositAddress represents the address to which a currency can be deposited
+// into the user's account.
+type DepositAddress struct {
+	Currency string
+	Address  string
+}
+
+// Deposit represents a deposit into the user's account.
+type Deposit struct {
+	ID            int64 `json:"Id"`
+	Amount        float64
+	Currency      string
+	Confirmations int
+	LastUpdated   time.Time
+	TxID          string `json:"TxId"`
+	CryptoAddress string
+}
+
+// UnmarshalJSON decodes a deposit, parsing its timestamp.
+func (d *Deposit) UnmarshalJSON(data []byte) error {
+	type alias Deposit
+	aux := struct {
+		*alias
+		LastUpdated string
+	}{alias: (*alias)(d)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	d.LastUpdated, err = parseTimestamp(aux.LastUpdated)
+	if err != nil {
+		return errors.Wrap(err, "invalid LastUpdated timestamp")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package bittrex_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestDeposit_UnmarshalJSON(t *testing.T) {
+	cases := map[string]struct {
+		in      string
+		exp     bittrex.Deposit
+		wantErr string
+	}{
+		"valid timestamp": {
+			in: `{"Id":1,"Amount":0.5,"Currency":"BTC","LastUpdated":"2014-02-13T07:38:53.883","TxId":"my-tx"}`,
+			exp: bittrex.Deposit{
+				ID:          1,
+				Amount:      0.5,
+				Currency:    "BTC",
+				LastUpdated: time.Date(2014, 2, 13, 7, 38, 53, 883000000, time.UTC),
+				TxID:        "my-tx",
+			},
+		},
+		"invalid timestamp": {
+			in:      `{"Id":1,"LastUpdated":"faketimestamp"}`,
+			wantErr: "invalid LastUpdated timestamp",
+		},
+	}
+
+	for id, tc := range cases {
+		var act bittrex.Deposit
+		err := json.Unmarshal([]byte(tc.in), &act)
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
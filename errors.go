@@ -0,0 +1,245 @@
+package bittrex
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Message codes that Bittrex returns when it rejects a request.
+const (
+	MessageInsufficientFunds         = "INSUFFICIENT_FUNDS"
+	MessageMinTradeRequirementNotMet = "MIN_TRADE_REQUIREMENT_NOT_MET"
+	MessageAPIKeyInvalid             = "APIKEY_INVALID"
+	MessageInvalidSignature          = "INVALID_SIGNATURE"
+	MessageInvalidMarket             = "INVALID_MARKET"
+	MessageOrderNotOpen              = "ORDER_NOT_OPEN"
+	MessageAddressGenerating         = "ADDRESS_GENERATING"
+)
+
+// APIError represents a request that was rejected, either by Bittrex itself
+// (an unsuccessful response) or by the infrastructure in front of it (an
+// unexpected HTTP status).
+//
+// Errors returned by the Client methods are wrapped, so use errors.As,
+// AsAPIError or the Is* functions in this file to inspect them.
+type APIError struct {
+	// Message is the message code returned by Bittrex, such as
+	// INSUFFICIENT_FUNDS. It is empty if the response was not a Bittrex
+	// response.
+	Message string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Endpoint is the path of the URL that was requested.
+	Endpoint string
+
+	// Body is the raw body of the response.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s rejected the request: %s", e.Endpoint, e.Message)
+	}
+	return fmt.Sprintf("%s rejected the request with status %d %s: %s",
+		e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// TransportError represents a failure to send a request or to receive its
+// response.
+type TransportError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s transport failure: %v", e.Endpoint, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError represents a response that could not be decoded.
+type DecodeError struct {
+	Endpoint   string
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s response could not be decoded: %v", e.Endpoint, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// WithdrawalRefusedError represents a withdrawal that was refused by the
+// Client's WithdrawalPolicy. Nothing was sent to Bittrex.
+type WithdrawalRefusedError struct {
+	Currency string
+	Quantity float64
+	Address  string
+	Reason   string
+}
+
+func (e *WithdrawalRefusedError) Error() string {
+	return fmt.Sprintf("withdrawal of %s %s to %s refused: %s",
+		strconv.FormatFloat(e.Quantity, 'f', -1, 64), e.Currency, e.Address, e.Reason)
+}
+
+// QueueOverflowError represents a trade that was discarded because the queue
+// of a handler was full and the QueueOverflow policy is OverflowError.
+type QueueOverflowError struct {
+	Handler int
+	Market  string
+	Trade   Trade
+}
+
+func (e *QueueOverflowError) Error() string {
+	return fmt.Sprintf("trade queue of handler %d for %s is full, dropped trade: %s", e.Handler, e.Market, e.Trade)
+}
+
+// NonceGapError represents exchange updates of a market that went missing. The
+// nonces from First to Last, inclusive, were never received, unless Late is
+// set.
+type NonceGapError struct {
+	Market      string
+	First, Last uint
+
+	// Late indicates that the exchange update did arrive, but only after the
+	// ordered trade handlers had moved on past it.
+	Late bool
+}
+
+func (e *NonceGapError) Error() string {
+	if e.Late {
+		return fmt.Sprintf("exchange update %d of %s arrived too late", e.First, e.Market)
+	}
+	if e.First == e.Last {
+		return fmt.Sprintf("exchange update %d of %s is missing", e.First, e.Market)
+	}
+	return fmt.Sprintf("exchange updates %d to %d of %s are missing", e.First, e.Last, e.Market)
+}
+
+// HubError represents a hub invocation that the SignalR server answered with
+// an error.
+type HubError struct {
+	Hub     string
+	Method  string
+	Message string
+}
+
+func (e *HubError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Message)
+}
+
+// AsAPIError finds the APIError that caused err, if there is one.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	found := findError(err, func(e error) bool {
+		apiErr, _ = e.(*APIError)
+		return apiErr != nil
+	})
+	return apiErr, found
+}
+
+// IsAPIError indicates if err was caused by Bittrex rejecting a request with
+// the specified message code.
+func IsAPIError(err error, message string) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.Message == message
+}
+
+// IsInsufficientFunds indicates if err was caused by an INSUFFICIENT_FUNDS
+// rejection.
+func IsInsufficientFunds(err error) bool {
+	return IsAPIError(err, MessageInsufficientFunds)
+}
+
+// IsMinTradeRequirementNotMet indicates if err was caused by a
+// MIN_TRADE_REQUIREMENT_NOT_MET rejection.
+func IsMinTradeRequirementNotMet(err error) bool {
+	return IsAPIError(err, MessageMinTradeRequirementNotMet)
+}
+
+// IsAPIKeyInvalid indicates if err was caused by an APIKEY_INVALID rejection.
+func IsAPIKeyInvalid(err error) bool {
+	return IsAPIError(err, MessageAPIKeyInvalid)
+}
+
+// IsInvalidMarket indicates if err was caused by an INVALID_MARKET rejection.
+func IsInvalidMarket(err error) bool {
+	return IsAPIError(err, MessageInvalidMarket)
+}
+
+// IsRateLimited indicates if err was caused by a 429 Too Many Requests
+// response.
+func IsRateLimited(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsTransportError indicates if err was caused by a failure to send a request
+// or to receive its response.
+func IsTransportError(err error) bool {
+	return findError(err, func(e error) bool {
+		_, ok := e.(*TransportError)
+		return ok
+	})
+}
+
+// IsDecodeError indicates if err was caused by a response that could not be
+// decoded.
+func IsDecodeError(err error) bool {
+	return findError(err, func(e error) bool {
+		_, ok := e.(*DecodeError)
+		return ok
+	})
+}
+
+// IsWithdrawalRefused indicates if err was caused by the WithdrawalPolicy
+// refusing a withdrawal.
+func IsWithdrawalRefused(err error) bool {
+	return findError(err, func(e error) bool {
+		_, ok := e.(*WithdrawalRefusedError)
+		return ok
+	})
+}
+
+// IsHubError indicates if err was caused by the SignalR server answering a hub
+// invocation with an error.
+func IsHubError(err error) bool {
+	return findError(err, func(e error) bool {
+		_, ok := e.(*HubError)
+		return ok
+	})
+}
+
+// findError walks the chain of errors that caused err and indicates if any of
+// them matches. Both github.com/pkg/errors causes and standard library
+// wrapping are followed.
+func findError(err error, match func(error) bool) bool {
+	for err != nil {
+		if match(err) {
+			return true
+		}
+
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,124 @@
+package bittrex_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestErrors(t *testing.T) {
+	rejection := func(msg string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"success":false,"message":"` + msg + `","result":null}`))
+		}
+	}
+
+	cases := map[string]struct {
+		handler           http.HandlerFunc
+		closeServer       bool
+		expMessage        string
+		expStatus         int
+		insufficientFunds bool
+		minTradeNotMet    bool
+		apiKeyInvalid     bool
+		invalidMarket     bool
+		rateLimited       bool
+		transportError    bool
+		decodeError       bool
+		apiError          bool
+	}{
+		"insufficient funds": {
+			handler:           rejection(bittrex.MessageInsufficientFunds),
+			expMessage:        bittrex.MessageInsufficientFunds,
+			expStatus:         http.StatusOK,
+			insufficientFunds: true,
+			apiError:          true,
+		},
+		"min trade requirement not met": {
+			handler:        rejection(bittrex.MessageMinTradeRequirementNotMet),
+			expMessage:     bittrex.MessageMinTradeRequirementNotMet,
+			expStatus:      http.StatusOK,
+			minTradeNotMet: true,
+			apiError:       true,
+		},
+		"invalid api key": {
+			handler:       rejection(bittrex.MessageAPIKeyInvalid),
+			expMessage:    bittrex.MessageAPIKeyInvalid,
+			expStatus:     http.StatusOK,
+			apiKeyInvalid: true,
+			apiError:      true,
+		},
+		"invalid market": {
+			handler:       rejection(bittrex.MessageInvalidMarket),
+			expMessage:    bittrex.MessageInvalidMarket,
+			expStatus:     http.StatusOK,
+			invalidMarket: true,
+			apiError:      true,
+		},
+		"rate limited": {
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTooManyRequests) },
+			expStatus:   http.StatusTooManyRequests,
+			rateLimited: true,
+			apiError:    true,
+		},
+		"malformed response": {
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`<html>`)) },
+			decodeError: true,
+		},
+		"unexpected result": {
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"success":true,"result":{}}`)) },
+			decodeError: true,
+		},
+		"server unavailable": {
+			closeServer:    true,
+			transportError: true,
+		},
+	}
+
+	for id, tc := range cases {
+		ts := httptest.NewServer(tc.handler)
+		if tc.closeServer {
+			ts.Close()
+		}
+
+		c := bittrex.New("", "")
+		c.MaxRetries = 1
+		c.HostAddr = ts.URL
+
+		_, err := c.Markets()
+		if err == nil {
+			t.Errorf("%v: expected an error, but none occurred", id)
+		}
+
+		apiErr, present := bittrex.AsAPIError(err)
+		equals(t, id, tc.apiError, present)
+		if present {
+			equals(t, id, tc.expMessage, apiErr.Message)
+			equals(t, id, tc.expStatus, apiErr.StatusCode)
+			equals(t, id, "/api/v1.1/public/getmarkets", apiErr.Endpoint)
+		}
+		equals(t, id, tc.insufficientFunds, bittrex.IsInsufficientFunds(err))
+		equals(t, id, tc.minTradeNotMet, bittrex.IsMinTradeRequirementNotMet(err))
+		equals(t, id, tc.apiKeyInvalid, bittrex.IsAPIKeyInvalid(err))
+		equals(t, id, tc.invalidMarket, bittrex.IsInvalidMarket(err))
+		equals(t, id, tc.rateLimited, bittrex.IsRateLimited(err))
+		equals(t, id, tc.transportError, bittrex.IsTransportError(err))
+		equals(t, id, tc.decodeError, bittrex.IsDecodeError(err))
+
+		// The standard library sees through the wrapping, too.
+		var stdAPIErr *bittrex.APIError
+		equals(t, id, tc.apiError, errors.As(err, &stdAPIErr))
+		if tc.apiError {
+			equals(t, id, apiErr, stdAPIErr)
+		}
+		var transportErr *bittrex.TransportError
+		equals(t, id, tc.transportError, errors.As(err, &transportErr))
+		var decodeErr *bittrex.DecodeError
+		equals(t, id, tc.decodeError, errors.As(err, &decodeErr))
+
+		ts.Close()
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/carterjones/bittrex"
@@ -11,17 +15,33 @@ import (
 func main() {
 	c := bittrex.New("", "")
 
-	// Create a candle handler that prints candles.
-	candleHandler := func(c bittrex.Candle) { fmt.Println(c) }
+	// Build one minute, five minute, hourly and daily candles from the same
+	// trades, and print each of them.
+	a, err := bittrex.NewCandleAggregator(time.Minute, 5*time.Minute, time.Hour, 24*time.Hour)
+	panicIfErr(err)
+	for _, interval := range a.Intervals() {
+		interval := interval
+		_, err = a.Register(interval, func(c bittrex.Candle) { fmt.Println(interval, c) })
+		panicIfErr(err)
+	}
 
-	// Begin processing candles at a one minute interval.
-	c.ProcessCandles(1*time.Minute, candleHandler)
+	// Begin processing candles.
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	panicIfErr(c.AggregateCandles(ctx, a))
 
 	// Subscribe to all Bittrex markets.
 	subscribeToAllMarkets(c)
 
-	// Wait indefinitely.
-	select {}
+	// Wait for an interrupt, then shut down cleanly.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	panicIfErr(c.Close(ctx))
 }
 
 func subscribeToAllMarkets(c *bittrex.Client) {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func main() {
+	c := bittrex.New("", "")
+
+	// Stream the summary of every market that changes.
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	summaries, err := c.MarketSummaryUpdates(ctx)
+	panicIfErr(err)
+
+	go func() {
+		for s := range summaries {
+			fmt.Printf("%s last=%.8f bid=%.8f ask=%.8f volume=%.2f\n", s.MarketName, s.Last, s.Bid, s.Ask, s.BaseVolume)
+		}
+	}()
+
+	// A single subscription covers all Bittrex markets.
+	panicIfErr(c.SubscribeSummaryDeltas(panicIfErr))
+
+	// Wait for an interrupt, then shut down cleanly.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	panicIfErr(c.Close(ctx))
+}
+
+func panicIfErr(err error) {
+	if err != nil {
+		log.Panic(err)
+	}
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/carterjones/bittrex"
 )
@@ -34,8 +39,15 @@ func main() {
 	}
 	log.Println("Successfully subscribed to all markets.")
 
-	// Wait indefinitely.
-	select {}
+	// Wait for an interrupt, then shut down cleanly.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	panicIfErr(c.Close(ctx))
 }
 
 func panicIfErr(err error) {
@@ -25,13 +25,37 @@ type tradeFill struct {
 	Rate      float64
 	Quantity  float64
 	TimeStamp string
+
+	// at is the time of the fill if it is already known, as is the case for
+	// the fills of the c2 hub. TimeStamp is unused then.
+	at time.Time
 }
 
 func (tf *tradeFill) time() (time.Time, error) {
-	layout := "2006-01-02T15:04:05.999"
-	t, err := time.Parse(layout, tf.TimeStamp)
+	if !tf.at.IsZero() {
+		return tf.at, nil
+	}
+
+	t, err := time.Parse(timestampLayout, tf.TimeStamp)
 	if err != nil {
 		return time.Time{}, errors.Wrap(err, "time parse failed")
 	}
 	return t, nil
 }
+
+// The types of the order book deltas in an exchange update.
+const (
+	orderDeltaAdd    = 0
+	orderDeltaRemove = 1
+	orderDeltaUpdate = 2
+)
+
+// exchangeState is the snapshot of a market's order book that is returned by
+// QueryExchangeState. Its nonce is that of the last exchange update that it
+// includes.
+type exchangeState struct {
+	MarketName string
+	Nounce     uint
+	Buys       []OrderBookEntry
+	Sells      []OrderBookEntry
+}
@@ -6,6 +6,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func red(s string) string {
@@ -64,3 +65,16 @@ func ok(tb testing.TB, id string, err error) {
 			filepath.Base(file), line, id, err.Error())
 	}
 }
+
+// eventually waits for the condition to become true, failing the test if it
+// doesn't within a few seconds.
+func eventually(tb testing.TB, id string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			_, file, line, _ := runtime.Caller(1)
+			tb.Fatalf(red("%s:%d %s | condition not met in time"), filepath.Base(file), line, id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,476 @@
+package bittrex
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OrderBookHandler processes a copy of an order book that has changed.
+type OrderBookHandler func(b OrderBook)
+
+// bookHandler is a registered order book handler.
+type bookHandler struct {
+	h OrderBookHandler
+
+	// inline makes the handler run in the goroutine that changed the book,
+	// so that it sees the changes in order. Such a handler must not block.
+	inline bool
+
+	// markets holds the markets whose books the handler gets. If it is nil,
+	// the handler gets the books of all markets.
+	markets map[string]bool
+}
+
+// liveOrderBook is the local copy of a market's order book. It is seeded from
+// a QueryExchangeState snapshot and kept current by applying the exchange
+// updates in nonce order.
+type liveOrderBook struct {
+	market string
+
+	// seeded indicates that the snapshot has been applied. Until then, the
+	// exchange updates are only buffered.
+	seeded bool
+
+	// The nonce of the last exchange update that was applied.
+	nonce uint
+
+	// The quantity at each rate on either side of the book.
+	bids map[float64]float64
+	asks map[float64]float64
+
+	// pending holds the exchange updates that can't be applied yet, keyed by
+	// their nonce. It holds at most maxPendingExchangeUpdates of them.
+	pending map[uint]exchangeUpdate
+
+	// resyncing indicates that the book is stale and is being seeded again.
+	resyncing bool
+
+	// gapTimer fires once a missing exchange update has been waited for long
+	// enough. It is nil if no update is missing. gapGen changes whenever a
+	// wait ends, so that the timers of earlier waits have no effect.
+	gapTimer *time.Timer
+	gapGen   int
+}
+
+// resyncTimeout is how long a single attempt to resync an order book may take.
+const resyncTimeout = 30 * time.Second
+
+// maxPendingExchangeUpdates is how many exchange updates a book buffers while
+// it can't apply them. Once that many are waiting, they are dropped and the
+// book is seeded again instead.
+const maxPendingExchangeUpdates = 1000
+
+func newLiveOrderBook(market string) *liveOrderBook {
+	return &liveOrderBook{
+		market:  market,
+		bids:    make(map[float64]float64),
+		asks:    make(map[float64]float64),
+		pending: make(map[uint]exchangeUpdate),
+	}
+}
+
+// seed replaces the contents of the book with the snapshot and then applies
+// the buffered exchange updates that follow it.
+func (b *liveOrderBook) seed(s exchangeState) {
+	b.bids = make(map[float64]float64, len(s.Buys))
+	for _, e := range s.Buys {
+		b.bids[e.Rate] = e.Quantity
+	}
+	b.asks = make(map[float64]float64, len(s.Sells))
+	for _, e := range s.Sells {
+		b.asks[e.Rate] = e.Quantity
+	}
+
+	b.nonce = s.Nounce
+	b.seeded = true
+	b.applyPending()
+}
+
+// add buffers the exchange update and applies every buffered update that is
+// next in line. It reports whether the book changed.
+func (b *liveOrderBook) add(eu exchangeUpdate) bool {
+	if b.seeded && eu.Nounce <= b.nonce {
+		// The update is already part of the book.
+		return false
+	}
+
+	b.pending[eu.Nounce] = eu
+	if !b.seeded {
+		return false
+	}
+
+	return b.applyPending()
+}
+
+// markStale makes the book buffer the exchange updates until it is seeded
+// again. It reports false if the book is already being resynced.
+func (b *liveOrderBook) markStale() bool {
+	if b.gapTimer != nil {
+		b.gapTimer.Stop()
+		b.gapTimer = nil
+	}
+	b.gapGen++
+	b.seeded = false
+
+	if b.resyncing {
+		return false
+	}
+	b.resyncing = true
+	return true
+}
+
+// pendingFull indicates if no more exchange updates can be buffered.
+func (b *liveOrderBook) pendingFull() bool {
+	return len(b.pending) >= maxPendingExchangeUpdates
+}
+
+// hasGap indicates if exchange updates are buffered because a preceding one
+// is missing.
+func (b *liveOrderBook) hasGap() bool {
+	return b.seeded && len(b.pending) > 0
+}
+
+// applyPending applies the buffered exchange updates that directly follow the
+// last applied one. It reports whether any were applied.
+func (b *liveOrderBook) applyPending() bool {
+	// Drop the updates that the book already includes.
+	for n := range b.pending {
+		if n <= b.nonce {
+			delete(b.pending, n)
+		}
+	}
+
+	applied := false
+	for {
+		eu, ok := b.pending[b.nonce+1]
+		if !ok {
+			return applied
+		}
+
+		delete(b.pending, eu.Nounce)
+		applyOrderDeltas(b.bids, eu.Buys)
+		applyOrderDeltas(b.asks, eu.Sells)
+		b.nonce = eu.Nounce
+		applied = true
+	}
+}
+
+// applyOrderDeltas applies the deltas to one side of a book.
+func applyOrderDeltas(levels map[float64]float64, deltas []tradeOrder) {
+	for _, d := range deltas {
+		switch d.Type {
+		case orderDeltaAdd, orderDeltaUpdate:
+			if d.Quantity > 0 {
+				levels[d.Rate] = d.Quantity
+			} else {
+				delete(levels, d.Rate)
+			}
+		case orderDeltaRemove:
+			delete(levels, d.Rate)
+		}
+	}
+}
+
+// snapshot returns a sorted copy of the book.
+func (b *liveOrderBook) snapshot() OrderBook {
+	ob := OrderBook{
+		Market: b.market,
+		Buy:    make([]OrderBookEntry, 0, len(b.bids)),
+		Sell:   make([]OrderBookEntry, 0, len(b.asks)),
+	}
+	for r, q := range b.bids {
+		ob.Buy = append(ob.Buy, OrderBookEntry{Quantity: q, Rate: r})
+	}
+	for r, q := range b.asks {
+		ob.Sell = append(ob.Sell, OrderBookEntry{Quantity: q, Rate: r})
+	}
+
+	sort.Slice(ob.Buy, func(i, j int) bool { return ob.Buy[i].Rate > ob.Buy[j].Rate })
+	sort.Slice(ob.Sell, func(i, j int) bool { return ob.Sell[i].Rate < ob.Sell[j].Rate })
+
+	return ob
+}
+
+// LiveOrderBook returns a copy of the local order book of the specified
+// market. The book is only maintained if TrackOrderBooks was set when the
+// market was subscribed to. It reports false if the book isn't available (yet)
+// or is stale because it is being resynced.
+func (c *Client) LiveOrderBook(market string) (OrderBook, bool) {
+	c.booksMux.Lock()
+	defer c.booksMux.Unlock()
+
+	b, ok := c.books[market]
+	if !ok || !b.seeded {
+		return OrderBook{}, false
+	}
+
+	return b.snapshot(), true
+}
+
+// RegisterOrderBookHandler saves the specified order book handler to a slice
+// of handlers that will be run against a copy of each local order book that
+// changes. If any markets are specified, the handler is only run against their
+// books. The handler stays registered until the returned registration is
+// unregistered.
+func (c *Client) RegisterOrderBookHandler(h OrderBookHandler, markets ...string) *Registration {
+	bh := &bookHandler{h: h, markets: marketSet(markets)}
+	c.registerOrderBookHandler(bh)
+	return newRegistration(func() { c.unregisterOrderBookHandler(bh) })
+}
+
+// registerOrderBookHandler adds the order book handler.
+func (c *Client) registerOrderBookHandler(bh *bookHandler) {
+	c.bookHandlersMux.Lock()
+	defer c.bookHandlersMux.Unlock()
+	c.bookHandlers = append(c.bookHandlers, bh)
+}
+
+// unregisterOrderBookHandler removes the order book handler.
+func (c *Client) unregisterOrderBookHandler(bh *bookHandler) {
+	c.bookHandlersMux.Lock()
+	defer c.bookHandlersMux.Unlock()
+
+	for i, other := range c.bookHandlers {
+		if other == bh {
+			c.bookHandlers = append(c.bookHandlers[:i:i], c.bookHandlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// trackOrderBook starts buffering the exchange updates of the specified market
+// so that they can be applied once the book is seeded.
+func (c *Client) trackOrderBook(market string) {
+	c.booksMux.Lock()
+	defer c.booksMux.Unlock()
+
+	if c.books == nil {
+		c.books = make(map[string]*liveOrderBook)
+	}
+	if _, ok := c.books[market]; !ok {
+		c.books[market] = newLiveOrderBook(market)
+	}
+}
+
+// untrackOrderBook discards the local order book of the specified market.
+func (c *Client) untrackOrderBook(market string) {
+	c.booksMux.Lock()
+	defer c.booksMux.Unlock()
+
+	b, ok := c.books[market]
+	if !ok {
+		return
+	}
+
+	if b.gapTimer != nil {
+		b.gapTimer.Stop()
+	}
+	delete(c.books, market)
+}
+
+// orderBookTracked indicates if the local order book of the specified market
+// is maintained.
+func (c *Client) orderBookTracked(market string) bool {
+	c.booksMux.Lock()
+	defer c.booksMux.Unlock()
+	_, ok := c.books[market]
+	return ok
+}
+
+// seedOrderBook queries the state of the specified market and uses it to seed
+// the local order book.
+func (c *Client) seedOrderBook(ctx context.Context, market string) error {
+	err := c.PublicRateLimiter.Wait(ctx)
+	if err != nil {
+		return errors.Wrap(err, "exchange state query canceled")
+	}
+
+	res, err := c.invoke(ctx, c2Hub, "QueryExchangeState", market)
+	if err != nil {
+		return errors.Wrap(err, "failed to query the exchange state")
+	}
+
+	// The state is returned as a c2 payload.
+	var payload string
+	err = json.Unmarshal(res, &payload)
+	if err != nil {
+		return errors.Wrap(err, "json unmarshal failed")
+	}
+
+	var state c2ExchangeState
+	err = decodeC2Payload(payload, &state)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the exchange state")
+	}
+	s := state.exchangeState()
+
+	c.booksMux.Lock()
+	b, ok := c.books[market]
+	if !ok {
+		c.booksMux.Unlock()
+		return errors.Errorf("the %s order book is no longer tracked", market)
+	}
+	b.seed(s)
+	b.resyncing = false
+	c.watchForGap(b)
+	c.notifyOrderBook(b.snapshot())
+	c.booksMux.Unlock()
+
+	return nil
+}
+
+// watchForGap starts or stops waiting for a missing exchange update of the
+// book, depending on whether one is missing. The books mutex must be held by
+// the caller.
+func (c *Client) watchForGap(b *liveOrderBook) {
+	if !b.hasGap() {
+		if b.gapTimer != nil {
+			b.gapTimer.Stop()
+			b.gapTimer = nil
+			b.gapGen++
+		}
+		return
+	}
+
+	if b.gapTimer != nil {
+		return
+	}
+
+	gen := b.gapGen
+	b.gapTimer = time.AfterFunc(c.NonceGapTimeout, func() { c.gapTimedOut(b, gen) })
+}
+
+// gapTimedOut marks the book as stale and resyncs it if the exchange update
+// that it has been waiting for is still missing.
+func (c *Client) gapTimedOut(b *liveOrderBook, gen int) {
+	c.booksMux.Lock()
+	if b.gapGen != gen || !b.hasGap() || c.books[b.market] != b {
+		c.booksMux.Unlock()
+		return
+	}
+
+	missing := b.nonce + 1
+	b.gapTimer = nil
+	start := b.markStale()
+	c.booksMux.Unlock()
+
+	if start {
+		err := errors.Errorf("exchange update %d of %s is missing", missing, b.market)
+		c.emitStreamEvent(ResyncStarted, b.market, err)
+		c.spawn(func() { c.resyncOrderBook(b.market) })
+	}
+}
+
+// resyncOrderBookAfter marks the local order book of the specified market as
+// stale and resyncs it, unless it is already being resynced. The cause is
+// reported with the ResyncStarted event.
+func (c *Client) resyncOrderBookAfter(market string, cause error) {
+	c.booksMux.Lock()
+	b, ok := c.books[market]
+	start := ok && b.markStale()
+	c.booksMux.Unlock()
+
+	if start {
+		c.emitStreamEvent(ResyncStarted, market, cause)
+		c.spawn(func() { c.resyncOrderBook(market) })
+	}
+}
+
+// resyncOrderBook seeds a stale order book again, retrying until it succeeds or
+// the client is closed.
+func (c *Client) resyncOrderBook(market string) {
+	done := c.closing()
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := c.lifetimeContext(resyncTimeout)
+		err := c.seedOrderBook(ctx, market)
+		cancel()
+		if err == nil {
+			c.emitStreamEvent(ResyncCompleted, market, nil)
+			return
+		}
+
+		// Give up once the market has been unsubscribed from.
+		if !c.orderBookTracked(market) {
+			return
+		}
+
+		err = errors.Wrapf(err, "failed to resync the %s order book", market)
+		c.emitStreamEvent(ResyncFailed, market, err)
+		c.reportError(err)
+
+		wait := backoff(c.RetryWaitDuration, attempt)
+		if wait <= 0 {
+			wait = time.Second
+		}
+		select {
+		case <-time.After(wait):
+		case <-done:
+			return
+		}
+	}
+}
+
+// updateOrderBook applies the exchange update to the local order book of its
+// market, if that book is being tracked. If the book has buffered as many
+// updates as it can, they are dropped along with this one and the book is
+// resynced.
+func (c *Client) updateOrderBook(eu exchangeUpdate) {
+	c.booksMux.Lock()
+	b, ok := c.books[eu.MarketName]
+	if !ok {
+		c.booksMux.Unlock()
+		return
+	}
+
+	if b.pendingFull() {
+		n := len(b.pending)
+		b.pending = make(map[uint]exchangeUpdate)
+		start := b.markStale()
+		c.booksMux.Unlock()
+
+		if start {
+			err := errors.Errorf("%d exchange updates of %s are waiting to be applied", n, b.market)
+			c.emitStreamEvent(ResyncStarted, b.market, err)
+			c.spawn(func() { c.resyncOrderBook(b.market) })
+		}
+		return
+	}
+
+	changed := b.add(eu)
+	c.watchForGap(b)
+	if changed && c.hasOrderBookHandlers() {
+		c.notifyOrderBook(b.snapshot())
+	}
+	c.booksMux.Unlock()
+}
+
+// hasOrderBookHandlers indicates if any order book handlers are registered, so
+// that books aren't copied for nobody.
+func (c *Client) hasOrderBookHandlers() bool {
+	c.bookHandlersMux.Lock()
+	defer c.bookHandlersMux.Unlock()
+	return len(c.bookHandlers) > 0
+}
+
+// notifyOrderBook runs the order book handlers against the book. The books
+// mutex must be held by the caller, so that the inline handlers see the books
+// of each market in order.
+func (c *Client) notifyOrderBook(ob OrderBook) {
+	c.bookHandlersMux.Lock()
+	defer c.bookHandlersMux.Unlock()
+	for _, bh := range c.bookHandlers {
+		if bh.markets != nil && !bh.markets[ob.Market] {
+			continue
+		}
+
+		if bh.inline {
+			bh.h(ob)
+		} else {
+			go bh.h(ob)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package bittrex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carterjones/signalr/hubs"
+)
+
+func TestLiveOrderBook(t *testing.T) {
+	snapshot := exchangeState{
+		Nounce: 10,
+		Buys:   []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}},
+		Sells:  []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+	}
+	add := func(nonce uint, rate, quantity float64) exchangeUpdate {
+		return exchangeUpdate{Nounce: nonce, Buys: []tradeOrder{{Type: orderDeltaAdd, Rate: rate, Quantity: quantity}}}
+	}
+
+	cases := map[string]struct {
+		before   []exchangeUpdate
+		after    []exchangeUpdate
+		expNonce uint
+		exp      OrderBook
+	}{
+		"snapshot only": {
+			expNonce: 10,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}},
+				Sell:   []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+		},
+		"all delta types": {
+			after: []exchangeUpdate{{
+				Nounce: 11,
+				Buys: []tradeOrder{
+					{Type: orderDeltaAdd, Rate: 0.95, Quantity: 5},
+					{Type: orderDeltaRemove, Rate: 0.8},
+				},
+				Sells: []tradeOrder{
+					{Type: orderDeltaUpdate, Rate: 1.1, Quantity: 6},
+					{Type: orderDeltaUpdate, Rate: 1.2, Quantity: 0},
+				},
+			}},
+			expNonce: 11,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 5, Rate: 0.95}, {Quantity: 1, Rate: 0.9}},
+				Sell:   []OrderBookEntry{{Quantity: 6, Rate: 1.1}},
+			},
+		},
+		"buffered before the snapshot": {
+			before:   []exchangeUpdate{add(9, 0.7, 9), add(11, 0.7, 7)},
+			expNonce: 11,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}, {Quantity: 7, Rate: 0.7}},
+				Sell:   []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+		},
+		"out of order": {
+			after:    []exchangeUpdate{add(13, 0.7, 3), add(12, 0.7, 2), add(11, 0.7, 1)},
+			expNonce: 13,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}, {Quantity: 3, Rate: 0.7}},
+				Sell:   []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+		},
+		"gap": {
+			after:    []exchangeUpdate{add(12, 0.7, 2)},
+			expNonce: 10,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}},
+				Sell:   []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+		},
+		"stale update": {
+			after:    []exchangeUpdate{add(10, 0.7, 2)},
+			expNonce: 10,
+			exp: OrderBook{
+				Market: "BTC-LTC",
+				Buy:    []OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}},
+				Sell:   []OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+		},
+	}
+
+	for id, tc := range cases {
+		b := newLiveOrderBook("BTC-LTC")
+		for _, eu := range tc.before {
+			equals(t, id, false, b.add(eu))
+		}
+		b.seed(snapshot)
+		for _, eu := range tc.after {
+			b.add(eu)
+		}
+
+		equals(t, id, tc.expNonce, b.nonce)
+		equals(t, id, tc.exp, b.snapshot())
+	}
+}
+
+func TestClient_updateOrderBook_Overflow(t *testing.T) {
+	// The snapshot of the resync is held back until the test has seen the
+	// stale book.
+	var queries int
+	release := make(chan struct{})
+	ts := NewMockSignalRServer(func(m hubs.ClientMsg) (interface{}, string) {
+		if m.M != "QueryExchangeState" {
+			return true, ""
+		}
+
+		nonce := 10
+		queries++
+		if queries > 1 {
+			<-release
+			nonce = 2000
+		}
+
+		payload, err := EncodeC2Payload(map[string]interface{}{"N": nonce, "Z": []interface{}{}, "S": []interface{}{}})
+		if err != nil {
+			return nil, err.Error()
+		}
+		return payload, ""
+	})
+	defer ts.Close()
+
+	c := New("", "")
+	c.TrackOrderBooks = true
+	c.NonceGapTimeout = time.Hour
+	ts.ConfigureClient(c)
+
+	events := make(chan StreamEvent, 10)
+	c.RegisterStreamEventHandler(func(e StreamEvent) { events <- e })
+	nextEvent := func() StreamEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a stream event")
+		}
+		return StreamEvent{}
+	}
+
+	ok(t, "subscribe", c.Subscribe("BTC-LTC", func(error) {}))
+
+	// Nonce 11 is missing, so the updates after it pile up until there are
+	// too many of them.
+	for n := uint(12); n < 12+maxPendingExchangeUpdates; n++ {
+		c.updateOrderBook(exchangeUpdate{MarketName: "BTC-LTC", Nounce: n})
+	}
+	_, found := c.LiveOrderBook("BTC-LTC")
+	equals(t, "gap", true, found)
+
+	c.updateOrderBook(exchangeUpdate{MarketName: "BTC-LTC", Nounce: 12 + maxPendingExchangeUpdates})
+	e := nextEvent()
+	equals(t, "started", ResyncStarted, e.Type)
+	errMatches(t, "started", e.Err, "1000 exchange updates of BTC-LTC are waiting to be applied")
+
+	c.booksMux.Lock()
+	pending := len(c.books["BTC-LTC"].pending)
+	c.booksMux.Unlock()
+	equals(t, "dropped", 0, pending)
+	_, found = c.LiveOrderBook("BTC-LTC")
+	equals(t, "stale", false, found)
+
+	close(release)
+	e = nextEvent()
+	equals(t, "completed", ResyncCompleted, e.Type)
+	c.booksMux.Lock()
+	nonce := c.books["BTC-LTC"].nonce
+	c.booksMux.Unlock()
+	equals(t, "resynced", uint(2000), nonce)
+}
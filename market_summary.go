@@ -0,0 +1,29 @@
+package bittrex
+
+import "time"
+
+// MarketSummary holds the trading activity of a market over the last 24 hours.
+type MarketSummary struct { // nolint: maligned
+	MarketName     string
+	High           float64
+	Low            float64
+	Volume         float64
+	Last           float64
+	BaseVolume     float64
+	TimeStamp      string // format: 2014-07-09T07:19:30.15
+	Bid            float64
+	Ask            float64
+	OpenBuyOrders  int
+	OpenSellOrders int
+	PrevDay        float64
+	Created        string
+}
+
+func (s MarketSummary) String() string {
+	return s.MarketName
+}
+
+// Time returns this summary's timestamp converted into a time.Time object.
+func (s *MarketSummary) Time() (time.Time, error) {
+	return parseTimestamp(s.TimeStamp)
+}
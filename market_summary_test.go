@@ -0,0 +1,39 @@
+package bittrex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestMarketSummary_Time(t *testing.T) {
+	cases := map[string]struct {
+		in      bittrex.MarketSummary
+		exp     time.Time
+		wantErr string
+	}{
+		"valid timestamp": {
+			in:  bittrex.MarketSummary{TimeStamp: "2018-11-28T22:57:05.68"},
+			exp: time.Date(2018, 11, 28, 22, 57, 5, 680000000, time.UTC),
+		},
+		"no fractional seconds": {
+			in:  bittrex.MarketSummary{TimeStamp: "2018-11-28T22:57:05"},
+			exp: time.Date(2018, 11, 28, 22, 57, 5, 0, time.UTC),
+		},
+		"wrong format timestamp": {
+			in:      bittrex.MarketSummary{TimeStamp: "2018-11-28 22:57:05.68"},
+			wantErr: "failed to parse time",
+		},
+	}
+
+	for id, tc := range cases {
+		act, err := tc.in.Time()
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
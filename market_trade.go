@@ -0,0 +1,20 @@
+package bittrex
+
+import "time"
+
+// MarketTrade represents a trade that was recently filled in a market, as
+// returned by the market history.
+type MarketTrade struct {
+	ID        int64 `json:"Id"`
+	TimeStamp string
+	Quantity  float64
+	Price     float64
+	Total     float64
+	FillType  string
+	OrderType string
+}
+
+// Time returns this trade's timestamp converted into a time.Time object.
+func (t *MarketTrade) Time() (time.Time, error) {
+	return parseTimestamp(t.TimeStamp)
+}
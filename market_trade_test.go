@@ -0,0 +1,35 @@
+package bittrex_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestMarketTrade_Time(t *testing.T) {
+	cases := map[string]struct {
+		in      bittrex.MarketTrade
+		exp     time.Time
+		wantErr string
+	}{
+		"valid timestamp": {
+			in:  bittrex.MarketTrade{TimeStamp: "2018-11-28T22:57:20.623"},
+			exp: time.Date(2018, 11, 28, 22, 57, 20, 623000000, time.UTC),
+		},
+		"not even a valid time identifier": {
+			in:      bittrex.MarketTrade{TimeStamp: "faketimestamp"},
+			wantErr: "failed to parse time",
+		},
+	}
+
+	for id, tc := range cases {
+		act, err := tc.in.Time()
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
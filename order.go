@@ -39,10 +39,120 @@ func (o *Order) Rate() float64 {
 
 // Time returns this order's timestamp converted into a time.Time object.
 func (o *Order) Time() (time.Time, error) {
-	layout := "2006-01-02T15:04:05.99"
-	t, err := time.Parse(layout, o.TimeStamp)
+	return parseTimestamp(o.TimeStamp)
+}
+
+// OrderID is the UUID that Bittrex assigns to an order when it is placed.
+type OrderID string
+
+func (id OrderID) String() string {
+	return string(id)
+}
+
+// PlacedOrder represents an order that was accepted by Bittrex.
+type PlacedOrder struct {
+	ID       OrderID
+	Market   string
+	Type     TradeType
+	Quantity float64
+	Rate     float64
+}
+
+// OpenOrder represents an order that is currently open in the user's account.
+type OpenOrder struct { // nolint: maligned
+	OrderUUID         OrderID `json:"OrderUuid"`
+	Exchange          string
+	OrderType         string
+	Quantity          float64
+	QuantityRemaining float64
+	Limit             float64
+	CommissionPaid    float64
+	Price             float64
+	PricePerUnit      float64
+	Opened            time.Time
+	Closed            *time.Time
+	CancelInitiated   bool
+	ImmediateOrCancel bool
+	IsConditional     bool
+	Condition         string
+	ConditionTarget   *float64
+}
+
+// UnmarshalJSON decodes an open order, parsing its timestamps.
+func (o *OpenOrder) UnmarshalJSON(data []byte) error {
+	type alias OpenOrder
+	aux := struct {
+		*alias
+		Opened string
+		Closed *string
+	}{alias: (*alias)(o)}
+
+	err := json.Unmarshal(data, &aux)
 	if err != nil {
-		return time.Time{}, errors.Wrap(err, "failed to parse time")
+		return err
 	}
-	return t, nil
+
+	o.Opened, err = parseTimestamp(aux.Opened)
+	if err != nil {
+		return errors.Wrap(err, "invalid Opened timestamp")
+	}
+	o.Closed, err = parseOptionalTimestamp(aux.Closed)
+	if err != nil {
+		return errors.Wrap(err, "invalid Closed timestamp")
+	}
+
+	return nil
+}
+
+// OrderDetail represents a single order in the user's account, whether it is
+// open or closed.
+type OrderDetail struct { // nolint: maligned
+	OrderUUID                  OrderID `json:"OrderUuid"`
+	Exchange                   string
+	Type                       string
+	Quantity                   float64
+	QuantityRemaining          float64
+	Limit                      float64
+	Reserved                   float64
+	ReserveRemaining           float64
+	CommissionReserved         float64
+	CommissionReserveRemaining float64
+	CommissionPaid             float64
+	Price                      float64
+	PricePerUnit               float64
+	Opened                     time.Time
+	Closed                     *time.Time
+	IsOpen                     bool
+	Sentinel                   string
+	CancelInitiated            bool
+	ImmediateOrCancel          bool
+	IsConditional              bool
+	Condition                  string
+	ConditionTarget            *float64
+}
+
+// UnmarshalJSON decodes an order, parsing its timestamps.
+func (o *OrderDetail) UnmarshalJSON(data []byte) error {
+	type alias OrderDetail
+	aux := struct {
+		*alias
+		Opened string
+		Closed *string
+	}{alias: (*alias)(o)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	o.Opened, err = parseTimestamp(aux.Opened)
+	if err != nil {
+		return errors.Wrap(err, "invalid Opened timestamp")
+	}
+	o.Closed, err = parseOptionalTimestamp(aux.Closed)
+	if err != nil {
+		return errors.Wrap(err, "invalid Closed timestamp")
+	}
+
+	return nil
 }
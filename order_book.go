@@ -0,0 +1,61 @@
+package bittrex
+
+// OrderBookSide selects which side of an order book to retrieve.
+type OrderBookSide string
+
+const (
+	// BuySide selects the buy orders (bids).
+	BuySide OrderBookSide = "buy"
+
+	// SellSide selects the sell orders (asks).
+	SellSide OrderBookSide = "sell"
+
+	// BothSides selects both the buy and the sell orders.
+	BothSides OrderBookSide = "both"
+)
+
+// OrderBookEntry represents the total quantity of the orders at a rate.
+type OrderBookEntry struct {
+	Quantity float64
+	Rate     float64
+}
+
+// OrderBook holds the buy orders, sorted from the highest to the lowest rate,
+// and the sell orders, sorted from the lowest to the highest rate, of a market.
+type OrderBook struct {
+	Market string           `json:"-"`
+	Buy    []OrderBookEntry `json:"buy"`
+	Sell   []OrderBookEntry `json:"sell"`
+}
+
+// BestBid returns the buy order with the highest rate. It reports false if
+// there are no buy orders.
+func (b OrderBook) BestBid() (OrderBookEntry, bool) {
+	if len(b.Buy) == 0 {
+		return OrderBookEntry{}, false
+	}
+	return b.Buy[0], true
+}
+
+// BestAsk returns the sell order with the lowest rate. It reports false if
+// there are no sell orders.
+func (b OrderBook) BestAsk() (OrderBookEntry, bool) {
+	if len(b.Sell) == 0 {
+		return OrderBookEntry{}, false
+	}
+	return b.Sell[0], true
+}
+
+// DepthAt returns the total quantity of the orders at the specified rate, on
+// either side of the book.
+func (b OrderBook) DepthAt(rate float64) float64 {
+	var quantity float64
+	for _, side := range [][]OrderBookEntry{b.Buy, b.Sell} {
+		for _, e := range side {
+			if e.Rate == rate {
+				quantity += e.Quantity
+			}
+		}
+	}
+	return quantity
+}
@@ -0,0 +1,45 @@
+package bittrex_test
+
+import (
+	"testing"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestOrderBook_Best(t *testing.T) {
+	cases := map[string]struct {
+		in       bittrex.OrderBook
+		expBid   bittrex.OrderBookEntry
+		expAsk   bittrex.OrderBookEntry
+		expOK    bool
+		rate     float64
+		expDepth float64
+	}{
+		"normal": {
+			in: bittrex.OrderBook{
+				Buy:  []bittrex.OrderBookEntry{{Quantity: 1, Rate: 0.9}, {Quantity: 2, Rate: 0.8}},
+				Sell: []bittrex.OrderBookEntry{{Quantity: 3, Rate: 1.1}, {Quantity: 4, Rate: 1.2}},
+			},
+			expBid:   bittrex.OrderBookEntry{Quantity: 1, Rate: 0.9},
+			expAsk:   bittrex.OrderBookEntry{Quantity: 3, Rate: 1.1},
+			expOK:    true,
+			rate:     1.2,
+			expDepth: 4,
+		},
+		"empty": {
+			rate: 1.2,
+		},
+	}
+
+	for id, tc := range cases {
+		bid, ok := tc.in.BestBid()
+		equals(t, id, tc.expOK, ok)
+		equals(t, id, tc.expBid, bid)
+
+		ask, ok := tc.in.BestAsk()
+		equals(t, id, tc.expOK, ok)
+		equals(t, id, tc.expAsk, ask)
+
+		equals(t, id, tc.expDepth, tc.in.DepthAt(tc.rate))
+	}
+}
@@ -1,6 +1,7 @@
 package bittrex_test
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -65,3 +66,80 @@ func TestOrder_Time(t *testing.T) {
 		}
 	}
 }
+
+func TestOpenOrder_UnmarshalJSON(t *testing.T) {
+	closed := time.Date(2014, 7, 9, 4, 1, 0, 0, time.UTC)
+	cases := map[string]struct {
+		in      string
+		exp     bittrex.OpenOrder
+		wantErr string
+	}{
+		"open": {
+			in: `{"OrderUuid":"my-uuid","Exchange":"BTC-LTC","Opened":"2014-07-09T03:55:48.77","Closed":null}`,
+			exp: bittrex.OpenOrder{
+				OrderUUID: "my-uuid",
+				Exchange:  "BTC-LTC",
+				Opened:    time.Date(2014, 7, 9, 3, 55, 48, 770000000, time.UTC),
+			},
+		},
+		"closed": {
+			in: `{"OrderUuid":"my-uuid","Opened":"2014-07-09T03:55:48","Closed":"2014-07-09T04:01:00"}`,
+			exp: bittrex.OpenOrder{
+				OrderUUID: "my-uuid",
+				Opened:    time.Date(2014, 7, 9, 3, 55, 48, 0, time.UTC),
+				Closed:    &closed,
+			},
+		},
+		"invalid opened timestamp": {
+			in:      `{"Opened":"faketimestamp"}`,
+			wantErr: "invalid Opened timestamp",
+		},
+		"invalid closed timestamp": {
+			in:      `{"Opened":"2014-07-09T03:55:48","Closed":"faketimestamp"}`,
+			wantErr: "invalid Closed timestamp",
+		},
+	}
+
+	for id, tc := range cases {
+		var act bittrex.OpenOrder
+		err := json.Unmarshal([]byte(tc.in), &act)
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
+
+func TestOrderDetail_UnmarshalJSON(t *testing.T) {
+	cases := map[string]struct {
+		in      string
+		exp     bittrex.OrderDetail
+		wantErr string
+	}{
+		"open": {
+			in: `{"OrderUuid":"my-uuid","IsOpen":true,"Opened":"2014-07-13T07:45:46.27","Closed":null}`,
+			exp: bittrex.OrderDetail{
+				OrderUUID: "my-uuid",
+				IsOpen:    true,
+				Opened:    time.Date(2014, 7, 13, 7, 45, 46, 270000000, time.UTC),
+			},
+		},
+		"invalid opened timestamp": {
+			in:      `{"Opened":"2014-07-13 07:45:46"}`,
+			wantErr: "invalid Opened timestamp",
+		},
+	}
+
+	for id, tc := range cases {
+		var act bittrex.OrderDetail
+		err := json.Unmarshal([]byte(tc.in), &act)
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
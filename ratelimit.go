@@ -0,0 +1,142 @@
+package bittrex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimiter is a token bucket that limits how often requests are sent to
+// Bittrex. Tokens are added at a fixed rate up to a maximum (the burst size),
+// and each request takes one token. It is safe for concurrent use.
+//
+// A nil RateLimiter does not limit anything.
+type RateLimiter struct {
+	// The number of tokens added per second and the maximum number of tokens
+	// the bucket can hold.
+	rate  float64
+	burst float64
+
+	// The number of tokens currently in the bucket. This is negative when
+	// callers are waiting for tokens that haven't been added yet.
+	tokens float64
+
+	// The last time tokens were added to the bucket.
+	last time.Time
+
+	mux sync.Mutex
+
+	// A function that returns a value representing "now". We can replace this
+	// value to simplify testing.
+	nower nower
+}
+
+// NewRateLimiter creates a rate limiter that allows rate requests per second
+// on average, with bursts of up to burst requests. The bucket starts out full.
+// A rate that is not positive means no limit is applied.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		nower:  defaultNower{},
+	}
+	l.last = l.nower.Now()
+
+	return l
+}
+
+// Wait blocks until a token is available and takes it. If the context is done
+// before then, the token is given back and an error is returned.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "rate limiter wait canceled")
+	}
+
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+
+	// Reserve a token. If none are available, the balance goes negative and
+	// we wait for the time it takes to pay it back.
+	l.mux.Lock()
+	l.refill()
+	l.tokens--
+	wait := l.delay(0)
+	l.mux.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the reservation back so other callers don't wait for it.
+		l.mux.Lock()
+		l.tokens++
+		l.mux.Unlock()
+		return errors.Wrap(ctx.Err(), "rate limiter wait canceled")
+	}
+}
+
+// Tokens returns the number of tokens currently available. It is negative if
+// callers are waiting for tokens.
+func (l *RateLimiter) Tokens() float64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.refill()
+	return l.tokens
+}
+
+// Delay returns how long a call to Wait would currently block.
+func (l *RateLimiter) Delay() time.Duration {
+	if l == nil || l.rate <= 0 {
+		return 0
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.refill()
+	return l.delay(1)
+}
+
+// refill adds the tokens that accumulated since the last refill. The mutex must
+// be held by the caller.
+func (l *RateLimiter) refill() {
+	now := l.nower.Now()
+	elapsed := now.Sub(l.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	l.tokens += elapsed.Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// delay returns how long it takes until the bucket holds the specified number
+// of tokens. The mutex must be held by the caller.
+func (l *RateLimiter) delay(tokens float64) time.Duration {
+	missing := tokens - l.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / l.rate * float64(time.Second))
+}
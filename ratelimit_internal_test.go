@@ -0,0 +1,111 @@
+package bittrex
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2010, 7, 5, 4, 3, 2, 1, time.UTC)
+
+	cases := map[string]struct {
+		rate      float64
+		burst     int
+		takes     int
+		elapsed   time.Duration
+		expTokens float64
+		expDelay  time.Duration
+	}{
+		"full bucket": {
+			rate:      2,
+			burst:     5,
+			expTokens: 5,
+		},
+		"partially used": {
+			rate:      2,
+			burst:     5,
+			takes:     3,
+			expTokens: 2,
+		},
+		"empty bucket": {
+			rate:      2,
+			burst:     5,
+			takes:     5,
+			expTokens: 0,
+			expDelay:  500 * time.Millisecond,
+		},
+		"refilled": {
+			rate:      2,
+			burst:     5,
+			takes:     5,
+			elapsed:   time.Second,
+			expTokens: 2,
+		},
+		"refill is capped at the burst size": {
+			rate:      2,
+			burst:     5,
+			takes:     1,
+			elapsed:   time.Hour,
+			expTokens: 5,
+		},
+		"invalid burst": {
+			rate:      2,
+			burst:     0,
+			expTokens: 1,
+		},
+	}
+
+	for id, tc := range cases {
+		n := &fakeNower{now: start}
+		l := NewRateLimiter(tc.rate, tc.burst)
+		l.nower = n
+		l.last = start
+
+		for i := 0; i < tc.takes; i++ {
+			ok(t, id, l.Wait(context.Background()))
+		}
+		n.now = n.now.Add(tc.elapsed)
+
+		equals(t, id, tc.expTokens, l.Tokens())
+		equals(t, id, tc.expDelay, l.Delay())
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	// Allow one request every 50ms.
+	l := NewRateLimiter(20, 1)
+
+	begin := time.Now()
+	for i := 0; i < 3; i++ {
+		ok(t, "wait", l.Wait(context.Background()))
+	}
+	if elapsed := time.Since(begin); elapsed < 90*time.Millisecond {
+		t.Errorf("three waits took %v, expected at least 100ms", elapsed)
+	}
+
+	// A canceled wait gives its token back. Use a slow limiter whose only
+	// token has been taken so the wait can't succeed before the deadline.
+	slow := NewRateLimiter(0.001, 1)
+	ok(t, "drain", slow.Wait(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	before := slow.Tokens()
+	errMatches(t, "canceled", slow.Wait(ctx), "rate limiter wait canceled: context deadline exceeded")
+	if after := slow.Tokens(); after < before {
+		t.Errorf("tokens went from %v to %v after a canceled wait", before, after)
+	}
+
+	// A nil limiter never blocks.
+	var nilLimiter *RateLimiter
+	ok(t, "nil", nilLimiter.Wait(context.Background()))
+	equals(t, "nil", time.Duration(0), nilLimiter.Delay())
+}
+
+type fakeNower struct {
+	now time.Time
+}
+
+func (n *fakeNower) Now() time.Time {
+	return n.now
+}
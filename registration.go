@@ -0,0 +1,22 @@
+package bittrex
+
+import (
+	"sync"
+)
+
+// Registration represents a registered handler.
+type Registration struct {
+	unregister func()
+	once       sync.Once
+}
+
+func newRegistration(unregister func()) *Registration {
+	return &Registration{unregister: unregister}
+}
+
+// Unregister removes the handler, so that it isn't run against anything that
+// arrives afterwards. Runs that are already under way aren't waited for.
+// Calling Unregister more than once has no effect.
+func (r *Registration) Unregister() {
+	r.once.Do(r.unregister)
+}
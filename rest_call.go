@@ -1,6 +1,7 @@
 package bittrex
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
@@ -8,19 +9,23 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
 type restCall struct {
-	req     *http.Request
-	res     *restResponse
-	resBody []byte
+	req           *http.Request
+	res           *restResponse
+	resBody       []byte
+	resStatusCode int
 
 	httpClient    *http.Client
 	httpTransport *http.Transport
@@ -34,6 +39,27 @@ type restCall struct {
 
 	params map[string]string
 
+	// The context that the call is bound to. If it is nil, the background
+	// context is used.
+	ctx context.Context
+
+	// The maximum number of attempts and the base duration to wait between
+	// them. Subsequent waits grow exponentially.
+	maxRetries        int
+	retryWaitDuration time.Duration
+
+	// The rate limiters for public and authenticated calls. Each attempt
+	// waits for a token from the appropriate one.
+	publicLimiter  *RateLimiter
+	privateLimiter *RateLimiter
+
+	// mutating indicates that the call changes state on the server (e.g.
+	// placing an order), so it must not be retried blindly. Such calls are
+	// only retried if the request never reached the server or if notApplied
+	// confirms that the failed attempt had no effect.
+	mutating   bool
+	notApplied func() (bool, error)
+
 	// A function that returns a value representing "now". We can replace this
 	// value to simplify testing.
 	nower nower
@@ -109,8 +135,176 @@ func (dn defaultNower) Now() time.Time {
 	return time.Now()
 }
 
-// TODO(carter): add some type of retry capability here
 func (rc *restCall) doGenericCall(uri string, requiresAuth bool) error {
+	attempts := rc.maxRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, reached, err := rc.attempt(uri, requiresAuth)
+		if err == nil {
+			return nil
+		}
+
+		// Errors that won't go away by trying again are returned as they are.
+		if !retry {
+			return err
+		}
+
+		err = errors.Wrapf(err, "attempt %d of %d failed", attempt, attempts)
+		if attempt >= attempts {
+			return err
+		}
+
+		// Stop if the caller is no longer interested in the result.
+		ctx := rc.context()
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "gave up after: %v", err)
+		}
+
+		// Wait before the next attempt.
+		select {
+		case <-time.After(backoff(rc.retryWaitDuration, attempt)):
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "gave up after: %v", err)
+		}
+
+		// Only retry a mutating call if we know the failed attempt didn't
+		// change anything on the server.
+		if rc.mutating && reached {
+			if rc.notApplied == nil {
+				return errors.Wrap(err, "request may have been applied, not retrying")
+			}
+
+			ok, verr := rc.notApplied()
+			if verr != nil {
+				return errors.Wrapf(err, "request may have been applied, not retrying (verification failed: %v)", verr)
+			}
+			if !ok {
+				return errors.Wrap(err, "request was applied, not retrying")
+			}
+		}
+	}
+}
+
+// attempt performs a single HTTP request. In addition to any error that
+// occurred, it reports whether the error is transient (retry) and whether the
+// request may have reached the Bittrex server (reached).
+func (rc *restCall) attempt(uri string, requiresAuth bool) (retry, reached bool, err error) {
+	c := rc.client()
+
+	// Wait for our turn to send the request.
+	limiter := rc.publicLimiter
+	if requiresAuth {
+		limiter = rc.privateLimiter
+	}
+	err = limiter.Wait(rc.context())
+	if err != nil {
+		return false, false, err
+	}
+
+	err = rc.buildRequest(uri, requiresAuth)
+	if err != nil {
+		return false, false, err
+	}
+
+	endpoint := rc.req.URL.Path
+
+	// Execute the API call.
+	var respRaw *http.Response
+	respRaw, err = c.Do(rc.req)
+	if err != nil {
+		err = &TransportError{Endpoint: endpoint, Err: err}
+		return true, !isDialError(err), errors.Wrap(err, "failed to execute the api call")
+	}
+	rc.resStatusCode = respRaw.StatusCode
+
+	// Get the response body.
+	rc.resBody, err = getBody(respRaw.Body)
+	if err != nil {
+		err = &TransportError{Endpoint: endpoint, Err: err}
+		return true, true, errors.Wrap(err, "failed to get body")
+	}
+
+	// Cloudflare challenges and rate limiting responses are returned before
+	// the request is handled by Bittrex.
+	switch {
+	case isCloudflareChallenge(respRaw, rc.resBody):
+		err = &APIError{StatusCode: respRaw.StatusCode, Endpoint: endpoint, Body: rc.resBody}
+		return true, false, errors.Wrap(err, "cloudflare challenge")
+	case respRaw.StatusCode == http.StatusTooManyRequests:
+		err = &APIError{StatusCode: respRaw.StatusCode, Endpoint: endpoint, Body: rc.resBody}
+		return true, false, errors.Wrap(err, "rate limited")
+	case respRaw.StatusCode >= 500:
+		err = &APIError{StatusCode: respRaw.StatusCode, Endpoint: endpoint, Body: rc.resBody}
+		return true, true, errors.Wrap(err, "server error")
+	}
+
+	// Convert the results.
+	rc.res, err = getRestResponse(endpoint, respRaw.StatusCode, rc.resBody)
+	if err != nil {
+		return false, true, errors.Wrap(err, "failed to get the rest response")
+	}
+
+	return false, true, nil
+}
+
+// buildRequest creates the request for the specified URI, including the URL
+// parameters and, if requiresAuth is set, the signature.
+func (rc *restCall) buildRequest(uri string, requiresAuth bool) error {
+	var err error
+	rc.req, err = http.NewRequestWithContext(rc.context(), "GET", uri, nil)
+	if err != nil {
+		return errors.Wrap(err, "get request creation failed")
+	}
+
+	// Add the URL parameters to the request.
+	rc.req.URL = addParamsToURL(rc.params, rc.req.URL)
+
+	// Prepare authorization parameters and headers.
+	if requiresAuth {
+		rc.addAuthData()
+	}
+
+	return nil
+}
+
+// requestV1_1 creates the request that doV1_1 would send, without sending it.
+func (rc *restCall) requestV1_1(api string, requiresAuth bool) (*http.Request, error) {
+	err := rc.buildRequest(rc.hostAddr+"/api/v1.1/"+api, requiresAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.req, nil
+}
+
+// decodeResult unmarshals the result of a successful call into v.
+func (rc *restCall) decodeResult(v interface{}) error {
+	err := json.Unmarshal(*rc.res.Result, v)
+	if err != nil {
+		return &DecodeError{
+			Endpoint:   rc.req.URL.Path,
+			StatusCode: rc.resStatusCode,
+			Body:       rc.resBody,
+			Err:        err,
+		}
+	}
+
+	return nil
+}
+
+// context returns the context that the call is bound to.
+func (rc *restCall) context() context.Context {
+	if rc.ctx == nil {
+		return context.Background()
+	}
+	return rc.ctx
+}
+
+// client returns the HTTP client used to perform the call.
+func (rc *restCall) client() *http.Client {
 	// Create a client.
 	var c *http.Client
 	if rc.httpClient == nil {
@@ -129,41 +323,57 @@ func (rc *restCall) doGenericCall(uri string, requiresAuth bool) error {
 		c.Transport = rc.httpTransport
 	}
 
-	// Create a new request.
-	var err error
-	rc.req, err = http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return errors.Wrap(err, "get request creation failed")
-	}
+	return c
+}
 
-	// Add the URL parameters to the request.
-	rc.req.URL = addParamsToURL(rc.params, rc.req.URL)
+// backoff returns how long to wait after the specified attempt. The wait
+// doubles with each attempt, and half of it is randomized so that many
+// clients don't retry in lockstep.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 || attempt < 1 {
+		return 0
+	}
 
-	// Prepare authorization parameters and headers.
-	if requiresAuth {
-		rc.addAuthData()
+	// Cap the exponent so the shift can't overflow.
+	if attempt > 16 {
+		attempt = 16
 	}
 
-	// Execute the API call.
-	var respRaw *http.Response
-	respRaw, err = c.Do(rc.req)
-	if err != nil {
-		return errors.Wrap(err, "failed to execute the api call")
+	d := base << uint(attempt-1)
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // nolint: gas, gosec
+}
+
+// isDialError indicates if the error occurred while establishing a connection,
+// which means the request never reached the server.
+func isDialError(err error) bool {
+	if te, ok := err.(*TransportError); ok {
+		err = te.Err
+	}
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
 	}
 
-	// Get the response body.
-	rc.resBody, err = getBody(respRaw.Body)
-	if err != nil {
-		return errors.Wrap(err, "failed to get body")
+	oe, ok := err.(*net.OpError)
+	return ok && oe.Op == "dial"
+}
+
+// isCloudflareChallenge indicates if the response is a Cloudflare challenge
+// page rather than a response from Bittrex. All responses pass through
+// Cloudflare, so only the challenge itself is trusted as a sign that the
+// request was stopped there: the cf-mitigated header or the markers of the
+// challenge page.
+func isCloudflareChallenge(resp *http.Response, body []byte) bool {
+	if resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusForbidden {
+		return false
 	}
 
-	// Convert the results.
-	rc.res, err = getRestResponse(rc.resBody, respRaw.Status)
-	if err != nil {
-		return errors.Wrap(err, "failed to get the rest response")
+	if strings.EqualFold(resp.Header.Get("cf-mitigated"), "challenge") {
+		return true
 	}
 
-	return nil
+	page := strings.ToLower(string(body))
+	return strings.Contains(page, "cf-chl") || strings.Contains(page, "jschl")
 }
 
 func newRestCall(httpClient *http.Client, httpTransport *http.Transport, apiKey, apiSecret, hostAddr string) *restCall {
@@ -183,7 +393,7 @@ func getBody(readCloser io.ReadCloser) (body []byte, err error) {
 		if derr != nil {
 			if err != nil {
 				err = errors.Wrapf(err, "error in defer")
-				err = errors.Wrapf(err, derr.Error())
+				err = errors.Wrap(err, derr.Error())
 			} else {
 				err = errors.Wrap(derr, "error in defer")
 			}
@@ -198,23 +408,38 @@ func getBody(readCloser io.ReadCloser) (body []byte, err error) {
 	return body, nil
 }
 
-func getRestResponse(body []byte, status string) (*restResponse, error) {
+func getRestResponse(endpoint string, statusCode int, body []byte) (*restResponse, error) {
 	// Unmarshal the response body.
 	rr := &restResponse{}
 	err := json.Unmarshal(body, &rr)
 	if err != nil {
-		debugMessage("status: %v, body: %v", status, body)
-		return nil, errors.Wrap(err, "json unmarshal failed")
+		debugMessage("status: %v, body: %v", statusCode, body)
+		return nil, &DecodeError{
+			Endpoint:   endpoint,
+			StatusCode: statusCode,
+			Body:       body,
+			Err:        errors.Wrap(err, "json unmarshal failed"),
+		}
 	}
 
 	// Verify the response was marked as a success.
 	if !rr.Success {
-		return nil, errors.Errorf("success is non-existent or false: %s", string(body))
+		return nil, &APIError{
+			Message:    rr.Message,
+			StatusCode: statusCode,
+			Endpoint:   endpoint,
+			Body:       body,
+		}
 	}
 
 	// Make sure a response was set.
 	if rr.Result == nil {
-		return nil, errors.Errorf("no result exists: %s", string(body))
+		return nil, &DecodeError{
+			Endpoint:   endpoint,
+			StatusCode: statusCode,
+			Body:       body,
+			Err:        errors.Errorf("no result exists: %s", string(body)),
+		}
 	}
 
 	return rr, nil
@@ -1,6 +1,7 @@
 package bittrex
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -154,14 +155,14 @@ func TestDoGenericCall(t *testing.T) {
 			customURI:    ":",
 			handlerFunc:  successfulHandler,
 			requiresAuth: false,
-			wantErr:      "get request creation failed: parse :: missing protocol scheme",
+			wantErr:      "get request creation failed: parse \":\": missing protocol scheme",
 		},
 		"failed to execute api call": {
 			rc:           newRestCall(nil, nil, "mykey", "mysecret", ""),
 			api:          workingAPI,
 			handlerFunc:  causeWriteResponseTimeout,
 			requiresAuth: false,
-			wantErr:      "hello/world: EOF",
+			wantErr:      "hello/world\": EOF",
 		},
 		"requires auth": {
 			rc:           newRestCall(nil, nil, "mykey", "mysecret", ""),
@@ -181,7 +182,7 @@ func TestDoGenericCall(t *testing.T) {
 					conn.Close()
 				}
 			},
-			wantErr: "failed to get body: read failed: unexpected EOF",
+			wantErr: "failed to get body: /hello/world transport failure: read failed: unexpected EOF",
 		},
 		"get rest response failure": {
 			rc:  newRestCall(nil, nil, "mykey", "mysecret", ""),
@@ -190,7 +191,7 @@ func TestDoGenericCall(t *testing.T) {
 				w.Write([]byte(`{}`))
 			},
 			requiresAuth: false,
-			wantErr:      "failed to get the rest response: success is non-existent or false: {}",
+			wantErr:      "failed to get the rest response: /hello/world rejected the request with status 200 OK: {}",
 		},
 	}
 
@@ -220,6 +221,215 @@ func TestDoGenericCall(t *testing.T) {
 	}
 }
 
+func TestDoGenericCall_Retry(t *testing.T) {
+	failuresWithBody := func(n int, status int, header http.Header, body string) func(int) http.HandlerFunc {
+		return func(hit int) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if hit <= n {
+					for k, v := range header {
+						w.Header()[k] = v
+					}
+					w.WriteHeader(status)
+					w.Write([]byte(body))
+					return
+				}
+				w.Write([]byte(`{"success":true,"result":"hello world"}`))
+			}
+		}
+	}
+	failures := func(n int, status int, header http.Header) func(int) http.HandlerFunc {
+		return failuresWithBody(n, status, header, "")
+	}
+	cloudflare := http.Header{"Server": []string{"cloudflare"}}
+	challenge := http.Header{"Server": []string{"cloudflare"}, "Cf-Mitigated": []string{"challenge"}}
+
+	cases := map[string]struct {
+		handler    func(hit int) http.HandlerFunc
+		maxRetries int
+		mutating   bool
+		notApplied func() (bool, error)
+		expHits    int
+		wantErr    string
+	}{
+		"server error then success": {
+			handler:    failures(2, http.StatusBadGateway, nil),
+			maxRetries: 3,
+			expHits:    3,
+		},
+		"rate limited then success": {
+			handler:    failures(1, http.StatusTooManyRequests, nil),
+			maxRetries: 3,
+			expHits:    2,
+		},
+		"retries exhausted": {
+			handler:    failures(5, http.StatusInternalServerError, nil),
+			maxRetries: 3,
+			expHits:    3,
+			wantErr:    "attempt 3 of 3 failed: server error: /hello/world rejected the request with status 500 Internal Server Error",
+		},
+		"no retries configured": {
+			handler: failures(1, http.StatusInternalServerError, nil),
+			expHits: 1,
+			wantErr: "attempt 1 of 1 failed",
+		},
+		"client errors are not retried": {
+			handler:    failures(1, http.StatusBadRequest, nil),
+			maxRetries: 3,
+			expHits:    1,
+			wantErr:    "json unmarshal failed",
+		},
+		"mutating call without verification": {
+			handler:    failures(1, http.StatusBadGateway, nil),
+			maxRetries: 3,
+			mutating:   true,
+			expHits:    1,
+			wantErr:    "request may have been applied, not retrying: attempt 1 of 3 failed",
+		},
+		"mutating call that was applied": {
+			handler:    failures(1, http.StatusBadGateway, nil),
+			maxRetries: 3,
+			mutating:   true,
+			notApplied: func() (bool, error) { return false, nil },
+			expHits:    1,
+			wantErr:    "request was applied, not retrying",
+		},
+		"mutating call that failed verification": {
+			handler:    failures(1, http.StatusBadGateway, nil),
+			maxRetries: 3,
+			mutating:   true,
+			notApplied: func() (bool, error) { return false, errors.New("lookup failed") },
+			expHits:    1,
+			wantErr:    "verification failed: lookup failed",
+		},
+		"mutating call that was not applied": {
+			handler:    failures(1, http.StatusBadGateway, nil),
+			maxRetries: 3,
+			mutating:   true,
+			notApplied: func() (bool, error) { return true, nil },
+			expHits:    2,
+		},
+		"mutating call challenged by cloudflare": {
+			handler:    failures(1, http.StatusServiceUnavailable, challenge),
+			maxRetries: 3,
+			mutating:   true,
+			expHits:    2,
+		},
+		"mutating call with a cloudflare challenge page": {
+			handler:    failuresWithBody(1, http.StatusForbidden, cloudflare, `<form id="challenge-form" action="/?__cf_chl_jschl_tk__=abc">`),
+			maxRetries: 3,
+			mutating:   true,
+			expHits:    2,
+		},
+		"mutating call failed behind cloudflare": {
+			handler:    failures(1, http.StatusServiceUnavailable, cloudflare),
+			maxRetries: 3,
+			mutating:   true,
+			expHits:    1,
+			wantErr:    "request may have been applied, not retrying: attempt 1 of 3 failed: server error",
+		},
+		"mutating call applied behind cloudflare": {
+			handler:    failures(1, http.StatusServiceUnavailable, cloudflare),
+			maxRetries: 3,
+			mutating:   true,
+			notApplied: func() (bool, error) { return false, nil },
+			expHits:    1,
+			wantErr:    "request was applied, not retrying",
+		},
+	}
+
+	for id, tc := range cases {
+		var hits int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			tc.handler(hits)(w, r)
+		}))
+
+		rc := newRestCall(nil, nil, "mykey", "mysecret", ts.URL)
+		rc.maxRetries = tc.maxRetries
+		rc.retryWaitDuration = time.Millisecond
+		rc.mutating = tc.mutating
+		rc.notApplied = tc.notApplied
+
+		act := rc.doGenericCall(ts.URL+"/hello/world", false)
+		if tc.wantErr != "" {
+			errMatches(t, id, act, tc.wantErr)
+		} else {
+			ok(t, id, act)
+		}
+		equals(t, id, tc.expHits, hits)
+
+		ts.Close()
+	}
+}
+
+func TestDoGenericCall_DialError(t *testing.T) {
+	// Grab an address that nothing is listening on.
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	addr := ts.URL
+	ts.Close()
+
+	var verified bool
+	rc := newRestCall(nil, nil, "mykey", "mysecret", addr)
+	rc.maxRetries = 2
+	rc.retryWaitDuration = time.Millisecond
+	rc.mutating = true
+	rc.notApplied = func() (bool, error) { verified = true; return true, nil }
+
+	// The request never reaches a server, so it is retried without asking if
+	// it was applied.
+	act := rc.doGenericCall(addr+"/hello/world", false)
+	errMatches(t, "dial error", act, "attempt 2 of 2 failed: failed to execute the api call")
+	equals(t, "dial error", false, verified)
+}
+
+func TestDoGenericCall_Canceled(t *testing.T) {
+	var hits int
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt and cancel the context before the retry.
+		hits++
+		cancel()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	rc := newRestCall(nil, nil, "mykey", "mysecret", ts.URL)
+	rc.ctx = ctx
+	rc.maxRetries = 3
+	rc.retryWaitDuration = time.Hour
+
+	act := rc.doGenericCall(ts.URL+"/hello/world", false)
+	errMatches(t, "canceled", act, "gave up after: attempt 1 of 3 failed")
+	equals(t, "canceled", context.Canceled, errors.Cause(act))
+	equals(t, "canceled", true, errors.Is(act, context.Canceled))
+	equals(t, "canceled", 1, hits)
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[string]struct {
+		base    time.Duration
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		"first attempt":   {base: time.Second, attempt: 1, min: 500 * time.Millisecond, max: time.Second},
+		"third attempt":   {base: time.Second, attempt: 3, min: 2 * time.Second, max: 4 * time.Second},
+		"huge attempt":    {base: time.Second, attempt: 1000, min: (1 << 14) * time.Second, max: (1 << 15) * time.Second},
+		"no base":         {base: 0, attempt: 3, min: 0, max: 0},
+		"invalid attempt": {base: time.Second, attempt: 0, min: 0, max: 0},
+	}
+
+	for id, tc := range cases {
+		for i := 0; i < 100; i++ {
+			act := backoff(tc.base, tc.attempt)
+			if act < tc.min || act > tc.max {
+				t.Errorf("%v: backoff %v is outside of [%v, %v]", id, act, tc.min, tc.max)
+				break
+			}
+		}
+	}
+}
+
 func TestNewRestCall(t *testing.T) {
 	cases := map[string]struct {
 		httpClient    *http.Client
@@ -337,15 +547,19 @@ func TestGetRestResponse(t *testing.T) {
 		},
 		"empty json object": {
 			body:    []byte("{}"),
-			wantErr: "success is non-existent or false: {}",
+			wantErr: "my/endpoint rejected the request with status 200 OK: {}",
 		},
 		"success is false": {
 			body:    []byte(`{"success":false}`),
-			wantErr: `success is non-existent or false: {"success":false}`,
+			wantErr: `my/endpoint rejected the request with status 200 OK: {"success":false}`,
+		},
+		"success is false with a message": {
+			body:    []byte(`{"success":false,"message":"INSUFFICIENT_FUNDS","result":null}`),
+			wantErr: "my/endpoint rejected the request: INSUFFICIENT_FUNDS",
 		},
 		"no result": {
 			body:    []byte(`{"success":true}`),
-			wantErr: `no result exists: {"success":true}`,
+			wantErr: `my/endpoint response could not be decoded: no result exists: {"success":true}`,
 		},
 	}
 
@@ -354,7 +568,7 @@ func TestGetRestResponse(t *testing.T) {
 			os.Setenv("DEBUG", "true")
 		}
 
-		act, err := getRestResponse(tc.body, "example status")
+		act, err := getRestResponse("my/endpoint", http.StatusOK, tc.body)
 		if tc.wantErr != "" {
 			errMatches(t, id, err, tc.wantErr)
 		} else {
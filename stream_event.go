@@ -0,0 +1,136 @@
+package bittrex
+
+import (
+	"time"
+)
+
+// StreamEventType represents a type of StreamEvent.
+type StreamEventType int
+
+func (t StreamEventType) String() string {
+	switch t {
+	case ResyncStarted:
+		return "RESYNC_STARTED"
+	case ResyncCompleted:
+		return "RESYNC_COMPLETED"
+	case ResyncFailed:
+		return "RESYNC_FAILED"
+	case Reconnected:
+		return "RECONNECTED"
+	case Resubscribed:
+		return "RESUBSCRIBED"
+	case ResubscribeFailed:
+		return "RESUBSCRIBE_FAILED"
+	case Reauthenticated:
+		return "REAUTHENTICATED"
+	case ReauthenticateFailed:
+		return "REAUTHENTICATE_FAILED"
+	case NonceGap:
+		return "NONCE_GAP"
+	default:
+		return "<invalid stream event type>"
+	}
+}
+
+const (
+	// ResyncStarted means an exchange update of the market went missing, so
+	// its order book is stale until it has been resynced.
+	ResyncStarted StreamEventType = iota
+
+	// ResyncCompleted means the order book of the market was seeded again
+	// and is current.
+	ResyncCompleted
+
+	// ResyncFailed means the order book of the market could not be seeded
+	// again. It stays stale and the resync is retried.
+	ResyncFailed
+
+	// Reconnected means the underlying SignalR client lost its connection
+	// and established a new one. The subscriptions are sent again.
+	Reconnected
+
+	// Resubscribed means the subscription to the market was sent again after
+	// a reconnect.
+	Resubscribed
+
+	// ResubscribeFailed means the subscription to the market could not be
+	// sent again after a reconnect. Its data stays silent until it is
+	// subscribed to again.
+	ResubscribeFailed
+
+	// Reauthenticated means the connection was authenticated again, either
+	// after a reconnect or because the authentication was about to expire.
+	Reauthenticated
+
+	// ReauthenticateFailed means the connection could not be authenticated
+	// again. The order and balance deltas stay silent until Authenticate is
+	// called again.
+	ReauthenticateFailed
+
+	// NonceGap means exchange updates of the market went missing, or arrived
+	// after a later one, so their trades were never delivered to the ordered
+	// trade handlers, which have moved on past them. Err is a *NonceGapError
+	// that holds the nonces.
+	NonceGap
+)
+
+// StreamEvent reports a change in the state of the websocket streams.
+type StreamEvent struct {
+	Type StreamEventType
+
+	// Market is the market that the event applies to, if any.
+	Market string
+
+	Time time.Time
+
+	// Err is the error that caused the event, if any.
+	Err error
+}
+
+// StreamEventHandler processes a stream event.
+type StreamEventHandler func(e StreamEvent)
+
+// streamEventHandler is a registered stream event handler.
+type streamEventHandler struct {
+	h StreamEventHandler
+}
+
+// RegisterStreamEventHandler saves the specified stream event handler to a
+// slice of handlers that will be run against each stream event. The handler
+// stays registered until the returned registration is unregistered.
+func (c *Client) RegisterStreamEventHandler(h StreamEventHandler) *Registration {
+	eh := &streamEventHandler{h: h}
+
+	c.streamEventHandlersMux.Lock()
+	defer c.streamEventHandlersMux.Unlock()
+	c.streamEventHandlers = append(c.streamEventHandlers, eh)
+
+	return newRegistration(func() {
+		c.streamEventHandlersMux.Lock()
+		defer c.streamEventHandlersMux.Unlock()
+
+		for i, other := range c.streamEventHandlers {
+			if other == eh {
+				c.streamEventHandlers = append(c.streamEventHandlers[:i:i], c.streamEventHandlers[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// emitStreamEvent runs the stream event handlers against an event of the
+// specified type.
+func (c *Client) emitStreamEvent(typ StreamEventType, market string, err error) {
+	e := StreamEvent{
+		Type:   typ,
+		Market: market,
+		Time:   time.Now().UTC(),
+		Err:    err,
+	}
+
+	c.streamEventHandlersMux.Lock()
+	defer c.streamEventHandlersMux.Unlock()
+	for _, eh := range c.streamEventHandlers {
+		go eh.h(e)
+	}
+}
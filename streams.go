@@ -0,0 +1,303 @@
+package bittrex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Trades returns a channel that receives the trades of the specified markets,
+// or of all markets if none are specified, in the order in which they
+// happened. The markets have to be subscribed to separately.
+//
+// The stream is fed by a queue like the one of an ordered trade handler, so
+// the QueueSize and QueueOverflow settings apply when the receiver falls
+// behind. The channel is closed once the context is done or the client is
+// closed.
+func (c *Client) Trades(ctx context.Context, markets ...string) (<-chan Trade, error) {
+	err := c.streamReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make(chan Trade)
+	stop := make(chan struct{})
+
+	// Each market's queue is drained by a goroutine of its own, so the sends
+	// are guarded to make sure that none happen after the channel is closed.
+	var sendMux sync.Mutex
+	closed := false
+
+	th := c.register(func(t Trade) {
+		sendMux.Lock()
+		defer sendMux.Unlock()
+
+		if closed {
+			return
+		}
+
+		select {
+		case trades <- t:
+		case <-stop:
+		}
+	}, true, markets...)
+
+	err = c.runStream(ctx, stop, func() {
+		c.unregister(th)
+
+		sendMux.Lock()
+		closed = true
+		close(trades)
+		sendMux.Unlock()
+	})
+	if err != nil {
+		c.unregister(th)
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// OrderBooks returns a channel that receives a copy of the local order book of
+// the specified markets, or of all markets if none are specified, each time it
+// changes. TrackOrderBooks must be set.
+//
+// If the receiver falls behind, only the latest book of each market is kept,
+// so no book is ever older than the one received before it. The channel is
+// closed once the context is done or the client is closed.
+func (c *Client) OrderBooks(ctx context.Context, markets ...string) (<-chan OrderBook, error) {
+	if !c.TrackOrderBooks {
+		return nil, errors.New("order books are not tracked")
+	}
+
+	err := c.streamReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make(chan OrderBook)
+	stop := make(chan struct{})
+
+	// The handler runs while the books are locked, so it only records the
+	// latest book of the market and leaves the sending to a goroutine.
+	latest := newLatestBuffer()
+	bh := &bookHandler{
+		inline:  true,
+		markets: marketSet(markets),
+		h:       func(ob OrderBook) { latest.set(ob.Market, ob) },
+	}
+	c.registerOrderBookHandler(bh)
+
+	forward := func() {
+		defer close(books)
+		defer c.unregisterOrderBookHandler(bh)
+
+		latest.forward(stop, func(v interface{}) bool {
+			select {
+			case books <- v.(OrderBook):
+				return true
+			case <-stop:
+				return false
+			}
+		})
+	}
+
+	if !c.spawn(forward) {
+		c.unregisterOrderBookHandler(bh)
+		return nil, errors.New("client is closed")
+	}
+
+	err = c.runStream(ctx, stop, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// Candles returns a channel that receives the candles of the specified
+// interval that are produced from the trades of the specified markets, or of
+// all markets if none are specified, the way ProcessCandles does. The markets
+// have to be subscribed to separately. The channel is closed once the context
+// is done or the client is closed.
+func (c *Client) Candles(ctx context.Context, interval time.Duration, markets ...string) (<-chan Candle, error) {
+	err := c.streamReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make(chan Candle)
+	stop := make(chan struct{})
+
+	emit := func(candle Candle) {
+		select {
+		case candles <- candle:
+		case <-stop:
+		}
+	}
+
+	a, err := c.candleAggregator(interval, emit)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.runCandleAggregator(a, markets, stop, func() { close(candles) }) {
+		return nil, errors.New("client is closed")
+	}
+
+	err = c.runStream(ctx, stop, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return candles, nil
+}
+
+// streamReady indicates if a stream can be started on behalf of a caller with
+// the specified context.
+func (c *Client) streamReady(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "stream not started")
+	}
+	if c.isClosed() {
+		return errors.New("client is closed")
+	}
+	return nil
+}
+
+// runStream closes stop and then runs cleanup once either the context is done
+// or the client is closed. If the client is already closed, stop is closed
+// right away and an error is returned, but cleanup isn't run.
+func (c *Client) runStream(ctx context.Context, stop chan struct{}, cleanup func()) error {
+	done := c.closing()
+	started := c.spawn(func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		close(stop)
+		cleanup()
+	})
+	if !started {
+		close(stop)
+		return errors.New("client is closed")
+	}
+
+	return nil
+}
+
+// streamBuffer holds the values that a stream has yet to send, so that the
+// handler feeding it never has to wait for the receiver.
+type streamBuffer struct {
+	pending []interface{}
+	ready   chan struct{}
+	mux     sync.Mutex
+}
+
+// newStreamBuffer creates an empty stream buffer.
+func newStreamBuffer() *streamBuffer {
+	return &streamBuffer{ready: make(chan struct{}, 1)}
+}
+
+// add appends the value to the buffer.
+func (b *streamBuffer) add(v interface{}) {
+	b.mux.Lock()
+	b.pending = append(b.pending, v)
+	b.mux.Unlock()
+
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// forward runs send against each buffered value, in order, until either stop
+// is closed or send reports false.
+func (b *streamBuffer) forward(stop <-chan struct{}, send func(v interface{}) bool) {
+	for {
+		select {
+		case <-b.ready:
+		case <-stop:
+			return
+		}
+
+		for {
+			b.mux.Lock()
+			if len(b.pending) == 0 {
+				b.mux.Unlock()
+				break
+			}
+			v := b.pending[0]
+			b.pending = b.pending[1:]
+			b.mux.Unlock()
+
+			if !send(v) {
+				return
+			}
+		}
+	}
+}
+
+// latestBuffer holds the latest value of each key that a stream has yet to
+// send, so that a receiver that falls behind skips the values in between.
+type latestBuffer struct {
+	latest  map[string]interface{}
+	order   []string
+	changed chan struct{}
+	mux     sync.Mutex
+}
+
+// newLatestBuffer creates an empty latest buffer.
+func newLatestBuffer() *latestBuffer {
+	return &latestBuffer{
+		latest:  make(map[string]interface{}),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// set replaces the value of the key. A key that is already waiting keeps its
+// place in line.
+func (b *latestBuffer) set(key string, v interface{}) {
+	b.mux.Lock()
+	if _, ok := b.latest[key]; !ok {
+		b.order = append(b.order, key)
+	}
+	b.latest[key] = v
+	b.mux.Unlock()
+
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// forward runs send against the value of each waiting key, in order, until
+// either stop is closed or send reports false.
+func (b *latestBuffer) forward(stop <-chan struct{}, send func(v interface{}) bool) {
+	for {
+		select {
+		case <-b.changed:
+		case <-stop:
+			return
+		}
+
+		for {
+			b.mux.Lock()
+			if len(b.order) == 0 {
+				b.mux.Unlock()
+				break
+			}
+			v := b.latest[b.order[0]]
+			delete(b.latest, b.order[0])
+			b.order = b.order[1:]
+			b.mux.Unlock()
+
+			if !send(v) {
+				return
+			}
+		}
+	}
+}
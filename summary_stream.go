@@ -0,0 +1,355 @@
+package bittrex
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The hub methods that subscribe to the summaries of all markets.
+const (
+	summaryDeltasMethod     = "SubscribeToSummaryDeltas"
+	summaryLiteDeltasMethod = "SubscribeToSummaryLiteDeltas"
+)
+
+// MarketSummaryHandler processes a market summary.
+type MarketSummaryHandler func(s MarketSummary)
+
+// summaryNonceKey identifies the summaries of a market that arrive through the
+// messages of a hub method, which each have their own nonces.
+type summaryNonceKey struct {
+	method string
+	market string
+}
+
+// summaryHandler is a registered market summary handler.
+type summaryHandler struct {
+	h MarketSummaryHandler
+
+	// inline makes the handler run in the goroutine that updated the summary,
+	// so that it sees the updates in order. Such a handler must not block.
+	inline bool
+
+	// markets holds the markets whose summaries the handler gets. If it is
+	// nil, the handler gets the summaries of all markets.
+	markets map[string]bool
+}
+
+// marketSummary converts the summary into a MarketSummary.
+func (s c2Summary) marketSummary() MarketSummary {
+	return MarketSummary{
+		MarketName:     s.MarketName,
+		High:           s.High,
+		Low:            s.Low,
+		Volume:         s.Volume,
+		Last:           s.Last,
+		BaseVolume:     s.BaseVolume,
+		TimeStamp:      c2Time(s.TimeStamp).Format(timestampLayout),
+		Bid:            s.Bid,
+		Ask:            s.Ask,
+		OpenBuyOrders:  s.OpenBuyOrders,
+		OpenSellOrders: s.OpenSellOrders,
+		PrevDay:        s.PrevDay,
+		Created:        c2Time(s.Created).Format(timestampLayout),
+	}
+}
+
+// SubscribeSummaryDeltas subscribes to the summaries of all markets. Bittrex
+// sends the summaries of the markets that changed about once a second, and
+// they are handed to the market summary handlers. See
+// RegisterMarketSummaryHandler and MarketSummaryUpdates. This is far cheaper
+// than subscribing to every market.
+func (c *Client) SubscribeSummaryDeltas(errHandler ErrHandler) error {
+	return c.SubscribeSummaryDeltasContext(context.Background(), errHandler)
+}
+
+// SubscribeSummaryDeltasContext is like SubscribeSummaryDeltas, but starting
+// the underlying SignalR client and sending the subscription are bound to the
+// specified context.
+func (c *Client) SubscribeSummaryDeltasContext(ctx context.Context, errHandler ErrHandler) error {
+	return c.subscribeSummaries(ctx, summaryDeltasMethod, errHandler)
+}
+
+// SubscribeSummaryLiteDeltas is like SubscribeSummaryDeltas, but Bittrex only
+// sends the last price and the base volume of each market. They are merged
+// into the latest full summary of the market, if there is one, before being
+// handed to the market summary handlers.
+func (c *Client) SubscribeSummaryLiteDeltas(errHandler ErrHandler) error {
+	return c.SubscribeSummaryLiteDeltasContext(context.Background(), errHandler)
+}
+
+// SubscribeSummaryLiteDeltasContext is like SubscribeSummaryLiteDeltas, but
+// starting the underlying SignalR client and sending the subscription are
+// bound to the specified context.
+func (c *Client) SubscribeSummaryLiteDeltasContext(ctx context.Context, errHandler ErrHandler) error {
+	return c.subscribeSummaries(ctx, summaryLiteDeltasMethod, errHandler)
+}
+
+// subscribeSummaries sends the summary subscription of the specified method,
+// unless it has already been sent. A call that comes in while the
+// subscription is being sent waits for its outcome.
+func (c *Client) subscribeSummaries(ctx context.Context, method string, errHandler ErrHandler) error {
+	c.subscriptionsMux.Lock()
+	if attempt, ok := c.summarySubscribing[method]; ok {
+		c.subscriptionsMux.Unlock()
+
+		// Another call is sending the subscription, so it decides the
+		// outcome of this one.
+		select {
+		case <-attempt.done:
+			return attempt.err
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "subscription canceled")
+		}
+	}
+	if c.summarySubscriptions[method] {
+		c.subscriptionsMux.Unlock()
+		return nil
+	}
+	if c.summarySubscriptions == nil {
+		c.summarySubscriptions = make(map[string]bool)
+	}
+	c.summarySubscriptions[method] = true
+	if c.summarySubscribing == nil {
+		c.summarySubscribing = make(map[string]*subscriptionAttempt)
+	}
+	attempt := &subscriptionAttempt{done: make(chan struct{})}
+	c.summarySubscribing[method] = attempt
+	c.subscriptionsMux.Unlock()
+
+	err := c.websocketReady(ctx, errHandler)
+	if err == nil {
+		err = errors.Wrap(c.PublicRateLimiter.Wait(ctx), "subscription canceled")
+	}
+	if err == nil {
+		err = c.invokeSubscription(ctx, method)
+	}
+
+	c.subscriptionsMux.Lock()
+	delete(c.summarySubscribing, method)
+	if err != nil {
+		// Forget about the subscription so the next call tries again.
+		delete(c.summarySubscriptions, method)
+	}
+	c.subscriptionsMux.Unlock()
+
+	attempt.err = err
+	close(attempt.done)
+
+	return err
+}
+
+// summarySubscriptionMethods returns the hub methods of the summary
+// subscriptions that were sent, in alphabetical order.
+func (c *Client) summarySubscriptionMethods() []string {
+	c.subscriptionsMux.Lock()
+	defer c.subscriptionsMux.Unlock()
+
+	methods := make([]string, 0, len(c.summarySubscriptions))
+	for m := range c.summarySubscriptions {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+// resubscribeSummaries sends the summary subscriptions again after a
+// reconnect.
+func (c *Client) resubscribeSummaries() {
+	// The nonces start over on the new connection.
+	c.summariesMux.Lock()
+	c.summaryNonces = nil
+	c.summariesMux.Unlock()
+
+	for _, method := range c.summarySubscriptionMethods() {
+		if c.isClosed() {
+			return
+		}
+
+		ctx, cancel := c.lifetimeContext(resyncTimeout)
+		err := c.PublicRateLimiter.Wait(ctx)
+		if err == nil {
+			err = c.invokeSubscription(ctx, method)
+		}
+		cancel()
+		if err != nil {
+			err = errors.Wrapf(err, "failed to send %s again", method)
+			c.emitStreamEvent(ResubscribeFailed, "", err)
+			c.reportError(err)
+			continue
+		}
+
+		c.emitStreamEvent(Resubscribed, "", nil)
+	}
+}
+
+// RegisterMarketSummaryHandler saves the specified market summary handler to a
+// slice of handlers that will be run against each summary update of the
+// specified markets, or of all markets if none are specified. The handler
+// stays registered until the returned registration is unregistered. See
+// SubscribeSummaryDeltas.
+func (c *Client) RegisterMarketSummaryHandler(h MarketSummaryHandler, markets ...string) *Registration {
+	return c.registerSummaryHandler(&summaryHandler{h: h, markets: marketSet(markets)})
+}
+
+// registerSummaryHandler adds the market summary handler.
+func (c *Client) registerSummaryHandler(sh *summaryHandler) *Registration {
+	c.summaryHandlersMux.Lock()
+	defer c.summaryHandlersMux.Unlock()
+	c.summaryHandlers = append(c.summaryHandlers, sh)
+
+	return newRegistration(func() {
+		c.summaryHandlersMux.Lock()
+		defer c.summaryHandlersMux.Unlock()
+
+		for i, other := range c.summaryHandlers {
+			if other == sh {
+				c.summaryHandlers = append(c.summaryHandlers[:i:i], c.summaryHandlers[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// MarketSummaryUpdates returns a channel that receives the summary of the
+// specified markets, or of all markets if none are specified, each time it is
+// updated. See SubscribeSummaryDeltas.
+//
+// If the receiver falls behind, only the latest summary of each market is
+// kept, so no summary is ever older than the one received before it. The
+// channel is closed once the context is done or the client is closed.
+func (c *Client) MarketSummaryUpdates(ctx context.Context, markets ...string) (<-chan MarketSummary, error) {
+	err := c.streamReady(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(chan MarketSummary)
+	stop := make(chan struct{})
+
+	latest := newLatestBuffer()
+	reg := c.registerSummaryHandler(&summaryHandler{
+		inline:  true,
+		markets: marketSet(markets),
+		h:       func(s MarketSummary) { latest.set(s.MarketName, s) },
+	})
+
+	forward := func() {
+		defer close(summaries)
+		defer reg.Unregister()
+
+		latest.forward(stop, func(v interface{}) bool {
+			select {
+			case summaries <- v.(MarketSummary):
+				return true
+			case <-stop:
+				return false
+			}
+		})
+	}
+
+	if !c.spawn(forward) {
+		reg.Unregister()
+		return nil, errors.New("client is closed")
+	}
+
+	err = c.runStream(ctx, stop, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// processSummaryDeltas decodes the argument of a uS message and updates the
+// summaries of its markets.
+func (c *Client) processSummaryDeltas(arg interface{}, errHandler ErrHandler) {
+	var d c2SummaryDeltas
+	err := decodeC2Arg(arg, &d)
+	if err != nil {
+		go errHandler(errors.Wrap(err, "failed to decode the summary deltas"))
+		return
+	}
+
+	c.summariesMux.Lock()
+	defer c.summariesMux.Unlock()
+
+	for _, s := range d.Deltas {
+		if c.advanceSummaryNonce(c2SummaryDeltasMethod, s.MarketName, d.Nonce) {
+			c.updateSummary(s.marketSummary())
+		}
+	}
+}
+
+// processSummaryLiteDeltas decodes the argument of a uL message and merges
+// its deltas into the summaries of their markets.
+func (c *Client) processSummaryLiteDeltas(arg interface{}, errHandler ErrHandler) {
+	var d c2LiteSummaryDeltas
+	err := decodeC2Arg(arg, &d)
+	if err != nil {
+		go errHandler(errors.Wrap(err, "failed to decode the lite summary deltas"))
+		return
+	}
+
+	c.summariesMux.Lock()
+	defer c.summariesMux.Unlock()
+
+	for _, l := range d.Deltas {
+		if !c.advanceSummaryNonce(c2LiteSummaryDeltasMethod, l.MarketName, d.Nonce) {
+			continue
+		}
+
+		s, ok := c.summaries[l.MarketName]
+		if !ok {
+			s = MarketSummary{MarketName: l.MarketName}
+		}
+		s.Last = l.Last
+		s.BaseVolume = l.BaseVolume
+		c.updateSummary(s)
+	}
+}
+
+// advanceSummaryNonce records the nonce of the message of the specified method
+// that holds a summary of the market. It reports false if a later message of
+// the method already held one, in which case the summary is stale and must be
+// ignored. The messages may arrive out of order, and a late one can still hold
+// the latest summaries of other markets, which is why the nonces are kept per
+// market. The summaries mutex must be held by the caller.
+func (c *Client) advanceSummaryNonce(method, market string, nonce uint) bool {
+	key := summaryNonceKey{method: method, market: market}
+	if last, ok := c.summaryNonces[key]; ok && nonce <= last {
+		return false
+	}
+
+	if c.summaryNonces == nil {
+		c.summaryNonces = make(map[summaryNonceKey]uint)
+	}
+	c.summaryNonces[key] = nonce
+
+	return true
+}
+
+// updateSummary saves the summary and runs the market summary handlers
+// against it. The summaries mutex must be held by the caller.
+func (c *Client) updateSummary(s MarketSummary) {
+	if c.summaries == nil {
+		c.summaries = make(map[string]MarketSummary)
+	}
+	c.summaries[s.MarketName] = s
+
+	c.summaryHandlersMux.Lock()
+	defer c.summaryHandlersMux.Unlock()
+	for _, sh := range c.summaryHandlers {
+		if sh.markets != nil && !sh.markets[s.MarketName] {
+			continue
+		}
+
+		if sh.inline {
+			sh.h(s)
+		} else {
+			go sh.h(s)
+		}
+	}
+}
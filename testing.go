@@ -1,12 +1,23 @@
 package bittrex
 
 import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/carterjones/bittrex/internal"
+	"github.com/carterjones/signalr"
+	"github.com/carterjones/signalr/hubs"
+	"github.com/gorilla/websocket"
 )
 
 // RequestParamsRecorder provides a way to record request parameters.
@@ -32,10 +43,22 @@ func panicIfErr(err error) {
 }
 
 var (
-	fixtureAccountGetbalances     = mustReadTestFixture("account_getbalances.json")
-	fixtureAccountGetorderhistory = mustReadTestFixture("account_getorderhistory.json")
-	fixturePubGetticks            = mustReadTestFixture("pub_market_getticks.json")
-	fixturePublicGetmarkets       = mustReadTestFixture("public_getmarkets.json")
+	fixtureAccountGetbalance           = mustReadTestFixture("account_getbalance.json")
+	fixtureAccountGetbalances          = mustReadTestFixture("account_getbalances.json")
+	fixtureAccountGetdepositaddress    = mustReadTestFixture("account_getdepositaddress.json")
+	fixtureAccountGetdeposithistory    = mustReadTestFixture("account_getdeposithistory.json")
+	fixtureAccountGetorder             = mustReadTestFixture("account_getorder.json")
+	fixtureAccountGetorderhistory      = mustReadTestFixture("account_getorderhistory.json")
+	fixtureAccountGetwithdrawalhistory = mustReadTestFixture("account_getwithdrawalhistory.json")
+	fixtureMarketGetopenorders         = mustReadTestFixture("market_getopenorders.json")
+	fixturePubGetticks                 = mustReadTestFixture("pub_market_getticks.json")
+	fixturePublicGetmarkets            = mustReadTestFixture("public_getmarkets.json")
+	fixturePublicGetticker             = mustReadTestFixture("public_getticker.json")
+	fixturePublicGetmarketsummaries    = mustReadTestFixture("public_getmarketsummaries.json")
+	fixturePublicGetmarketsummary      = mustReadTestFixture("public_getmarketsummary.json")
+	fixturePublicGetorderbook          = mustReadTestFixture("public_getorderbook.json")
+	fixturePublicGetmarkethistory      = mustReadTestFixture("public_getmarkethistory.json")
+	fixturePublicGetcurrencies         = mustReadTestFixture("public_getcurrencies.json")
 )
 
 // NewMockRestServer returns a new httptest server that can be used to record
@@ -49,12 +72,51 @@ func NewMockRestServer() (*httptest.Server, *RequestParamsRecorder) {
 		case "/api/v1.1/public/getmarkets":
 			_, err := w.Write(fixturePublicGetmarkets)
 			panicIfErr(err)
+		case "/api/v1.1/public/getticker":
+			_, err := w.Write(fixturePublicGetticker)
+			panicIfErr(err)
+		case "/api/v1.1/public/getmarketsummaries":
+			_, err := w.Write(fixturePublicGetmarketsummaries)
+			panicIfErr(err)
+		case "/api/v1.1/public/getmarketsummary":
+			_, err := w.Write(fixturePublicGetmarketsummary)
+			panicIfErr(err)
+		case "/api/v1.1/public/getorderbook":
+			_, err := w.Write(orderBookResponse(fixturePublicGetorderbook, r.URL.Query().Get("type")))
+			panicIfErr(err)
+		case "/api/v1.1/public/getmarkethistory":
+			_, err := w.Write(fixturePublicGetmarkethistory)
+			panicIfErr(err)
+		case "/api/v1.1/public/getcurrencies":
+			_, err := w.Write(fixturePublicGetcurrencies)
+			panicIfErr(err)
 		case "/api/v1.1/account/getorderhistory":
 			_, err := w.Write(fixtureAccountGetorderhistory)
 			panicIfErr(err)
 		case "/api/v1.1/account/getbalances":
 			_, err := w.Write(fixtureAccountGetbalances)
 			panicIfErr(err)
+		case "/api/v1.1/account/getbalance":
+			_, err := w.Write(fixtureAccountGetbalance)
+			panicIfErr(err)
+		case "/api/v1.1/account/getorder":
+			_, err := w.Write(fixtureAccountGetorder)
+			panicIfErr(err)
+		case "/api/v1.1/account/getdepositaddress":
+			_, err := w.Write(fixtureAccountGetdepositaddress)
+			panicIfErr(err)
+		case "/api/v1.1/account/getdeposithistory":
+			_, err := w.Write(fixtureAccountGetdeposithistory)
+			panicIfErr(err)
+		case "/api/v1.1/account/getwithdrawalhistory":
+			_, err := w.Write(fixtureAccountGetwithdrawalhistory)
+			panicIfErr(err)
+		case "/api/v1.1/account/withdraw":
+			_, err := w.Write([]byte(`{"success":true,"message":"","result":{"uuid":"68b5a16c-92de-11e3-ba3b-425861b86ab6"}}`))
+			panicIfErr(err)
+		case "/api/v1.1/market/getopenorders":
+			_, err := w.Write(fixtureMarketGetopenorders)
+			panicIfErr(err)
 		case "/api/v1.1/market/cancel":
 			// Note: the "market/cancel" documentation doesn't make sense at
 			// https://bittrex.com/home/api
@@ -70,8 +132,281 @@ func NewMockRestServer() (*httptest.Server, *RequestParamsRecorder) {
 		case "/Api/v2.0/pub/market/GetTicks":
 			_, err := w.Write(fixturePubGetticks)
 			panicIfErr(err)
+		case "/Api/v2.0/pub/market/GetLatestTick":
+			_, err := w.Write([]byte(`{"success":true,"message":"","result":[{"O":0.00067460,"H":0.00067625,"L":0.00067243,"C":0.00067251,"V":731.64231299,"T":"2018-02-12T07:10:00","BV":0.49260967}]}`))
+			panicIfErr(err)
 		default:
 			log.Println(r.URL.Path)
 		}
 	})), rr
 }
+
+// orderBookResponse converts an order book response holding both sides into a
+// response holding only the specified side, which is how Bittrex responds when
+// a single side is requested.
+func orderBookResponse(fixture []byte, side string) []byte {
+	if side != string(BuySide) && side != string(SellSide) {
+		return fixture
+	}
+
+	var res struct {
+		Success bool                        `json:"success"`
+		Message string                      `json:"message"`
+		Result  map[string]*json.RawMessage `json:"result"`
+	}
+	err := json.Unmarshal(fixture, &res)
+	panicIfErr(err)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"success": res.Success,
+		"message": res.Message,
+		"result":  res.Result[side],
+	})
+	panicIfErr(err)
+
+	return data
+}
+
+// InvocationHandler answers a hub invocation that was sent to a
+// MockSignalRServer. It returns the result of the invocation, or a non-empty
+// error message if the invocation failed.
+type InvocationHandler func(m hubs.ClientMsg) (result interface{}, errMsg string)
+
+// MockSignalRServer is a fake Bittrex SignalR server that can be used to test
+// the websocket functionality of the Client. It answers each hub invocation
+// using its InvocationHandler and can push hub messages to the connected
+// clients.
+type MockSignalRServer struct {
+	*httptest.Server
+
+	handler InvocationHandler
+
+	conns       []*mockSignalRConn
+	invocations []hubs.ClientMsg
+	msgID       int
+	mux         sync.Mutex
+
+	// done is closed when the server is closed, which stops the goroutines
+	// that serve the connections.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// mockSignalRConn is a websocket connection to a MockSignalRServer. Writes are
+// serialized because the websocket package doesn't allow concurrent writers.
+type mockSignalRConn struct {
+	ws  *websocket.Conn
+	mux sync.Mutex
+}
+
+func (c *mockSignalRConn) write(v interface{}) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.ws.WriteJSON(v)
+}
+
+// NewMockSignalRServer returns a new, started SignalR server. If h is nil,
+// every invocation succeeds with a result of true.
+func NewMockSignalRServer(h InvocationHandler) *MockSignalRServer {
+	if h == nil {
+		h = func(hubs.ClientMsg) (interface{}, string) { return true, "" }
+	}
+
+	s := &MockSignalRServer{handler: h, done: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/negotiate"):
+			signalr.TestNegotiate(w, r)
+		case strings.Contains(r.URL.Path, "/connect"), strings.Contains(r.URL.Path, "/reconnect"):
+			s.connect(w, r)
+		case strings.Contains(r.URL.Path, "/start"):
+			signalr.TestStart(w, r)
+		}
+	}))
+
+	return s
+}
+
+// ConfigureClient points the SignalR client underlying c at this server.
+func (s *MockSignalRServer) ConfigureClient(c *Client) {
+	c.signalrC.Host = strings.Replace(s.URL, "http://", "", -1)
+	c.signalrC.Scheme = signalr.HTTP
+	c.signalrC.HTTPClient = s.Client()
+}
+
+// connect upgrades a connection to a websocket and serves it until either side
+// closes it.
+func (s *MockSignalRServer) connect(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	conn := &mockSignalRConn{ws: ws}
+	s.mux.Lock()
+	s.conns = append(s.conns, conn)
+	s.mux.Unlock()
+
+	// Send the init message.
+	if conn.write(map[string]interface{}{"C": "init", "S": 1, "M": []interface{}{}}) != nil {
+		return
+	}
+
+	// Send keep-alive messages regularly. The SignalR client can't send
+	// anything while it waits for a message, so this keeps invocations from
+	// stalling.
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			if conn.write(struct{}{}) != nil {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	// Answer the invocations.
+	go func() {
+		for {
+			var m hubs.ClientMsg
+			if ws.ReadJSON(&m) != nil {
+				s.removeConn(conn)
+				return
+			}
+
+			s.mux.Lock()
+			s.invocations = append(s.invocations, m)
+			s.mux.Unlock()
+
+			result, errMsg := s.handler(m)
+			res := map[string]interface{}{"I": strconv.Itoa(m.I)}
+			if errMsg != "" {
+				res["E"] = errMsg
+			} else {
+				res["R"] = result
+			}
+			if conn.write(res) != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (s *MockSignalRServer) removeConn(conn *mockSignalRConn) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i, c := range s.conns {
+		if c == conn {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// Push sends a hub message with the specified arguments to every connected
+// client.
+func (s *MockSignalRServer) Push(hub, method string, args ...interface{}) error {
+	s.mux.Lock()
+	s.msgID++
+	msg := map[string]interface{}{
+		"C": "d-" + strconv.Itoa(s.msgID),
+		"M": []interface{}{
+			map[string]interface{}{"H": hub, "M": method, "A": args},
+		},
+	}
+	conns := append([]*mockSignalRConn(nil), s.conns...)
+	s.mux.Unlock()
+
+	for _, c := range conns {
+		err := c.write(msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PushC2 sends a message of the c2 hub with v encoded as its payload to every
+// connected client. See EncodeC2Payload.
+func (s *MockSignalRServer) PushC2(method string, v interface{}) error {
+	payload, err := EncodeC2Payload(v)
+	if err != nil {
+		return err
+	}
+	return s.Push("C2", method, payload)
+}
+
+// EncodeC2Payload encodes v the way the c2 hub encodes its payloads: as the
+// base64 encoding of raw deflate compressed JSON. The keys are used as they
+// are, so v should use the minified keys that Bittrex sends.
+func EncodeC2Payload(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return encodeC2Data(data)
+}
+
+// encodeC2Data compresses and encodes the JSON data as a c2 payload.
+func encodeC2Data(data []byte) (string, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return "", err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Invocations returns the hub invocations that were received so far.
+func (s *MockSignalRServer) Invocations() []hubs.ClientMsg {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]hubs.ClientMsg(nil), s.invocations...)
+}
+
+// Connections returns the number of clients that are currently connected.
+func (s *MockSignalRServer) Connections() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.conns)
+}
+
+// Close closes the connections of all clients and shuts the server down.
+func (s *MockSignalRServer) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.DropConnections()
+		s.Server.Close()
+	})
+}
+
+// DropConnections abruptly closes the connections of all clients, which makes
+// them reconnect.
+func (s *MockSignalRServer) DropConnections() {
+	s.mux.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mux.Unlock()
+
+	for _, c := range conns {
+		_ = c.ws.Close()
+	}
+}
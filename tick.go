@@ -46,10 +46,116 @@ func (t Tick) String() string {
 
 // Time returns a time object that is converted from the timestamp value.
 func (t *Tick) Time() (time.Time, error) {
-	layout := "2006-01-02T15:04:05"
-	tickTime, err := time.Parse(layout, t.Timestamp)
+	tickTime, err := time.Parse(timestampLayout, t.Timestamp)
 	if err != nil {
 		return time.Time{}, errors.Wrap(err, "time parse failed")
 	}
 	return tickTime, nil
 }
+
+// Candle converts the tick of the specified market into a candle. Ticks don't
+// include the number of trades or the buy and sell volumes, so those are zero.
+func (t *Tick) Candle(market string) (Candle, error) {
+	at, err := t.Time()
+	if err != nil {
+		return Candle{}, err
+	}
+
+	candle := Candle{
+		Market:     market,
+		Time:       at,
+		Open:       t.Open,
+		High:       t.High,
+		Low:        t.Low,
+		Close:      t.Close,
+		Volume:     t.Volume,
+		BaseVolume: t.BV,
+	}
+	candle.updateVWAP()
+
+	return candle, nil
+}
+
+// TickInterval represents the interval of the ticks that Bittrex returns.
+type TickInterval int
+
+func (i TickInterval) String() string {
+	switch i {
+	case TickOneMin:
+		return "oneMin"
+	case TickFiveMin:
+		return "fiveMin"
+	case TickThirtyMin:
+		return "thirtyMin"
+	case TickHour:
+		return "hour"
+	case TickDay:
+		return "day"
+	default:
+		return "<invalid tick interval>"
+	}
+}
+
+// Duration returns the length of the interval.
+func (i TickInterval) Duration() time.Duration {
+	switch i {
+	case TickOneMin:
+		return time.Minute
+	case TickFiveMin:
+		return 5 * time.Minute
+	case TickThirtyMin:
+		return 30 * time.Minute
+	case TickHour:
+		return time.Hour
+	case TickDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+const (
+	// TickOneMin is the interval of one minute ticks.
+	TickOneMin TickInterval = iota
+
+	// TickFiveMin is the interval of five minute ticks.
+	TickFiveMin
+
+	// TickThirtyMin is the interval of thirty minute ticks.
+	TickThirtyMin
+
+	// TickHour is the interval of hourly ticks.
+	TickHour
+
+	// TickDay is the interval of daily ticks.
+	TickDay
+)
+
+// TicksInRange returns the ticks whose time is at or after from and before to.
+// A zero from or to leaves that end of the range open. The ticks keep their
+// order.
+//
+// The range is only applied in memory. GetTicks can't be paged or limited to
+// a range, so the ticks have to be the full history of the interval, as
+// returned by TicksWithInterval, and narrowing them down saves no download.
+// Ticks outside of that history can't be reached at all.
+func TicksInRange(ticks []Tick, from, to time.Time) ([]Tick, error) {
+	var inRange []Tick
+	for _, t := range ticks {
+		at, err := t.Time()
+		if err != nil {
+			return nil, err
+		}
+
+		if !from.IsZero() && at.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !at.Before(to) {
+			continue
+		}
+
+		inRange = append(inRange, t)
+	}
+
+	return inRange, nil
+}
@@ -61,3 +61,142 @@ func TestTick_Time(t *testing.T) {
 		}
 	}
 }
+
+func TestTick_Candle(t *testing.T) {
+	cases := map[string]struct {
+		in      bittrex.Tick
+		exp     bittrex.Candle
+		wantErr string
+	}{
+		"normal": {
+			in: bittrex.Tick{
+				Timestamp: "2017-12-22T03:15:00",
+				Open:      2.1,
+				High:      3.0,
+				Low:       1.0,
+				Close:     2.2,
+				Volume:    4,
+				BV:        9,
+			},
+			exp: bittrex.Candle{
+				Market:     "BTC-WAVES",
+				Time:       time.Date(2017, 12, 22, 3, 15, 0, 0, time.UTC),
+				Open:       2.1,
+				High:       3.0,
+				Low:        1.0,
+				Close:      2.2,
+				Volume:     4,
+				BaseVolume: 9,
+				VWAP:       2.25,
+			},
+		},
+		"no volume": {
+			in: bittrex.Tick{
+				Timestamp: "2017-12-22T03:15:00",
+				Open:      2.1,
+				High:      2.1,
+				Low:       2.1,
+				Close:     2.1,
+			},
+			exp: bittrex.Candle{
+				Market: "BTC-WAVES",
+				Time:   time.Date(2017, 12, 22, 3, 15, 0, 0, time.UTC),
+				Open:   2.1,
+				High:   2.1,
+				Low:    2.1,
+				Close:  2.1,
+			},
+		},
+		"invalid timestamp": {
+			in:      bittrex.Tick{Timestamp: "faketimestamp"},
+			wantErr: "time parse failed",
+		},
+	}
+
+	for id, tc := range cases {
+		act, err := tc.in.Candle("BTC-WAVES")
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
+
+func TestTickInterval(t *testing.T) {
+	cases := map[string]struct {
+		in       bittrex.TickInterval
+		exp      string
+		duration time.Duration
+	}{
+		"one minute":   {in: bittrex.TickOneMin, exp: "oneMin", duration: time.Minute},
+		"five minutes": {in: bittrex.TickFiveMin, exp: "fiveMin", duration: 5 * time.Minute},
+		"30 minutes":   {in: bittrex.TickThirtyMin, exp: "thirtyMin", duration: 30 * time.Minute},
+		"hour":         {in: bittrex.TickHour, exp: "hour", duration: time.Hour},
+		"day":          {in: bittrex.TickDay, exp: "day", duration: 24 * time.Hour},
+		"invalid":      {in: bittrex.TickInterval(-1), exp: "<invalid tick interval>"},
+	}
+
+	for id, tc := range cases {
+		equals(t, id, tc.exp, tc.in.String())
+		equals(t, id, tc.duration, tc.in.Duration())
+	}
+}
+
+func TestTicksInRange(t *testing.T) {
+	ticks := []bittrex.Tick{
+		{Timestamp: "2017-12-22T03:14:00"},
+		{Timestamp: "2017-12-22T03:15:00"},
+		{Timestamp: "2017-12-22T03:16:00"},
+		{Timestamp: "2017-12-22T03:17:00"},
+	}
+	at := func(min int) time.Time { return time.Date(2017, 12, 22, 3, min, 0, 0, time.UTC) }
+
+	cases := map[string]struct {
+		ticks   []bittrex.Tick
+		from    time.Time
+		to      time.Time
+		exp     []bittrex.Tick
+		wantErr string
+	}{
+		"bounded": {
+			ticks: ticks,
+			from:  at(15),
+			to:    at(17),
+			exp:   ticks[1:3],
+		},
+		"open start": {
+			ticks: ticks,
+			to:    at(16),
+			exp:   ticks[:2],
+		},
+		"open end": {
+			ticks: ticks,
+			from:  at(16),
+			exp:   ticks[2:],
+		},
+		"unbounded": {
+			ticks: ticks,
+			exp:   ticks,
+		},
+		"empty range": {
+			ticks: ticks,
+			from:  at(20),
+		},
+		"invalid timestamp": {
+			ticks:   []bittrex.Tick{{Timestamp: "faketimestamp"}},
+			wantErr: "time parse failed",
+		},
+	}
+
+	for id, tc := range cases {
+		act, err := bittrex.TicksInRange(tc.ticks, tc.from, tc.to)
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}
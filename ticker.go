@@ -0,0 +1,8 @@
+package bittrex
+
+// Ticker holds the current bid, ask, and last trade price of a market.
+type Ticker struct {
+	Bid  float64
+	Ask  float64
+	Last float64
+}
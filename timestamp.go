@@ -0,0 +1,37 @@
+package bittrex
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timestampLayout is the layout of the timestamps returned by the REST API,
+// such as 2014-07-09T03:55:48.77. The number of fractional digits varies (and
+// they are sometimes left out), which time.Parse accepts with this layout.
+// Formatting keeps up to milliseconds, like Bittrex does. The timestamps are
+// in UTC.
+const timestampLayout = "2006-01-02T15:04:05.999"
+
+// parseTimestamp parses a timestamp returned by the REST API.
+func parseTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(timestampLayout, s)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to parse time")
+	}
+	return t, nil
+}
+
+// parseOptionalTimestamp parses a timestamp that may be null, such as the time
+// an order was closed.
+func parseOptionalTimestamp(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	t, err := parseTimestamp(*s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,517 @@
+package bittrex
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what happens to a trade that is delivered to a full
+// trade queue.
+type OverflowPolicy int
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "BLOCK"
+	case OverflowDropOldest:
+		return "DROP_OLDEST"
+	case OverflowError:
+		return "ERROR"
+	default:
+		return "<invalid overflow policy>"
+	}
+}
+
+const (
+	// OverflowBlock waits for the handler to make room in its queue. This
+	// holds up the processing of all incoming market data until it does. The
+	// responses to hub invocations still get through until MessageBufferSize
+	// messages are waiting, after which the connection isn't read from at
+	// all.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest trade in the queue to make room
+	// for the new one.
+	OverflowDropOldest
+
+	// OverflowError discards the new trade and reports a QueueOverflowError to
+	// the error handler.
+	OverflowError
+)
+
+// DefaultQueueSize is the default capacity of each trade queue.
+const DefaultQueueSize = 1000
+
+// QueueStats describes the trade queue of a handler for a market.
+type QueueStats struct {
+	// Handler is the position of the handler in the order of registration,
+	// starting at 0.
+	Handler int
+	Market  string
+
+	// Depth is the number of trades that are waiting for the handler and
+	// Capacity is the number of trades that fit in the queue.
+	Depth    int
+	Capacity int
+
+	// Delivered is the number of trades that the handler has processed and
+	// Dropped is the number of trades that were discarded because the queue
+	// was full.
+	Delivered uint64
+	Dropped   uint64
+}
+
+// tradeHandler is a registered trade handler, along with the queues that feed
+// it when the trades are delivered in order.
+type tradeHandler struct {
+	id int
+	h  TradeHandler
+
+	// ordered forces the trades to be delivered in order, whatever the
+	// OrderedDelivery setting of the client.
+	ordered bool
+
+	// markets holds the markets whose trades the handler gets. If it is nil,
+	// the handler gets the trades of all markets.
+	markets map[string]bool
+
+	// stop is closed when the handler is unregistered, which stops its
+	// queues from being drained.
+	stop chan struct{}
+
+	// queues holds the queue of each market. It is protected by the trade
+	// handlers mutex of the client.
+	queues map[string]*tradeQueue
+}
+
+// tradeQueue holds the trades of a market that are waiting for a handler. A
+// single goroutine drains it, so the handler sees the trades in order.
+type tradeQueue struct {
+	// The counters come first so that they are aligned for atomic access.
+	delivered uint64
+	dropped   uint64
+
+	trades chan Trade
+}
+
+// put adds the trade to the queue, applying the policy if the queue is full.
+// It reports false if the trade was discarded because of OverflowError. A
+// blocked put gives up once either done or stop is closed.
+func (q *tradeQueue) put(t Trade, policy OverflowPolicy, done, stop <-chan struct{}) bool {
+	switch policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.trades <- t:
+				return true
+			default:
+			}
+
+			select {
+			case <-q.trades:
+				atomic.AddUint64(&q.dropped, 1)
+			default:
+			}
+		}
+	case OverflowError:
+		select {
+		case q.trades <- t:
+			return true
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+			return false
+		}
+	default:
+		select {
+		case q.trades <- t:
+		case <-done:
+		case <-stop:
+		}
+		return true
+	}
+}
+
+// drain runs the handler against each queued trade until either done or stop
+// is closed.
+func (q *tradeQueue) drain(h TradeHandler, done, stop <-chan struct{}) {
+	for {
+		select {
+		case t := <-q.trades:
+			h(t)
+			atomic.AddUint64(&q.delivered, 1)
+		case <-done:
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tradeBatch holds the trades of an exchange update, along with its nonce.
+type tradeBatch struct {
+	market string
+	nonce  uint
+	trades []Trade
+}
+
+// tradeSequence puts the trade batches of a market back in nonce order. The
+// underlying SignalR client hands over each message in its own goroutine, so
+// the batches may arrive out of order.
+type tradeSequence struct {
+	// The nonce of the last batch that was released. Started indicates that
+	// the sequence has settled on its first batch, before which nothing is
+	// released.
+	nonce   uint
+	started bool
+
+	// pending holds the batches that can't be released yet, keyed by nonce.
+	pending map[uint]tradeBatch
+
+	// gapTimer fires once a missing batch has been waited for long enough,
+	// or once the sequence has waited long enough to start.
+	gapTimer *time.Timer
+}
+
+// tradeSequenceSettleTime is how long a new trade sequence waits for the
+// batches that were sent before its first one, so that it can start with the
+// earliest of them. It is shortened to NonceGapTimeout if that is shorter.
+const tradeSequenceSettleTime = 100 * time.Millisecond
+
+// release returns the pending batches that directly follow the last released
+// one, in order.
+func (s *tradeSequence) release() []tradeBatch {
+	if !s.started {
+		return nil
+	}
+
+	var batches []tradeBatch
+	for {
+		b, ok := s.pending[s.nonce+1]
+		if !ok {
+			return batches
+		}
+
+		delete(s.pending, b.nonce)
+		s.nonce = b.nonce
+		batches = append(batches, b)
+	}
+}
+
+// skip gives up on the missing batches and moves on to the first pending one.
+// It returns the nonces that were given up on, if any. A sequence that hasn't
+// started yet starts with the first pending batch instead, without giving up
+// on anything.
+func (s *tradeSequence) skip() (first, last uint, skipped bool) {
+	var next uint
+	found := false
+	for n := range s.pending {
+		if !found || n < next {
+			next = n
+			found = true
+		}
+	}
+
+	if !found {
+		return 0, 0, false
+	}
+	if !s.started {
+		s.nonce = next - 1
+		s.started = true
+		return 0, 0, false
+	}
+	if next == s.nonce+1 {
+		return 0, 0, false
+	}
+
+	first, last = s.nonce+1, next-1
+	s.nonce = last
+	return first, last, true
+}
+
+// forgetTradeSequence discards the trade sequence of the market, so that a new
+// one starts with the next batch that arrives.
+func (c *Client) forgetTradeSequence(market string) {
+	c.sequencesMux.Lock()
+	defer c.sequencesMux.Unlock()
+
+	if s, ok := c.sequences[market]; ok {
+		if s.gapTimer != nil {
+			s.gapTimer.Stop()
+		}
+		delete(c.sequences, market)
+	}
+}
+
+// QueueStats returns the state of the trade queues, ordered by handler and
+// market. Queues only exist for the handlers that get their trades in order.
+func (c *Client) QueueStats() []QueueStats {
+	c.tradeHandlersMux.Lock()
+	defer c.tradeHandlersMux.Unlock()
+
+	var stats []QueueStats
+	for _, th := range c.tradeHandlers {
+		for market, q := range th.queues {
+			stats = append(stats, QueueStats{
+				Handler:   th.id,
+				Market:    market,
+				Depth:     len(q.trades),
+				Capacity:  cap(q.trades),
+				Delivered: atomic.LoadUint64(&q.delivered),
+				Dropped:   atomic.LoadUint64(&q.dropped),
+			})
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Handler != stats[j].Handler {
+			return stats[i].Handler < stats[j].Handler
+		}
+		return stats[i].Market < stats[j].Market
+	})
+
+	return stats
+}
+
+// register adds a trade handler. If ordered is set, the handler gets its trades
+// in order even if OrderedDelivery isn't. If any markets are specified, the
+// handler only gets their trades.
+func (c *Client) register(h TradeHandler, ordered bool, markets ...string) *tradeHandler {
+	c.tradeHandlersMux.Lock()
+	defer c.tradeHandlersMux.Unlock()
+
+	th := &tradeHandler{
+		id:      c.tradeHandlerSeq,
+		h:       h,
+		ordered: ordered,
+		markets: marketSet(markets),
+		stop:    make(chan struct{}),
+		queues:  make(map[string]*tradeQueue),
+	}
+	c.tradeHandlerSeq++
+	c.tradeHandlers = append(c.tradeHandlers, th)
+
+	return th
+}
+
+// unregister removes the trade handler and stops draining its queues.
+func (c *Client) unregister(th *tradeHandler) {
+	c.tradeHandlersMux.Lock()
+	defer c.tradeHandlersMux.Unlock()
+
+	for i, other := range c.tradeHandlers {
+		if other == th {
+			c.tradeHandlers = append(c.tradeHandlers[:i:i], c.tradeHandlers[i+1:]...)
+			close(th.stop)
+			return
+		}
+	}
+}
+
+// marketSet turns the markets into a set. It returns nil if there are none.
+func marketSet(markets []string) map[string]bool {
+	if len(markets) == 0 {
+		return nil
+	}
+
+	set := make(map[string]bool, len(markets))
+	for _, m := range markets {
+		set[m] = true
+	}
+	return set
+}
+
+// handlers returns the registered trade handlers and indicates if any of them
+// get their trades in order.
+func (c *Client) handlers() ([]*tradeHandler, bool) {
+	c.tradeHandlersMux.Lock()
+	defer c.tradeHandlersMux.Unlock()
+
+	ordered := false
+	for _, th := range c.tradeHandlers {
+		ordered = ordered || th.ordered || c.OrderedDelivery
+	}
+
+	return append([]*tradeHandler(nil), c.tradeHandlers...), ordered
+}
+
+// queue returns the queue that feeds the trades of the market to the handler,
+// creating it if necessary. It returns nil if the client is closed.
+func (c *Client) queue(th *tradeHandler, market string) *tradeQueue {
+	c.tradeHandlersMux.Lock()
+	defer c.tradeHandlersMux.Unlock()
+
+	if q, ok := th.queues[market]; ok {
+		return q
+	}
+
+	size := c.QueueSize
+	if size < 1 {
+		size = 1
+	}
+	q := &tradeQueue{trades: make(chan Trade, size)}
+
+	done := c.closing()
+	if !c.spawn(func() { q.drain(th.h, done, th.stop) }) {
+		return nil
+	}
+	th.queues[market] = q
+
+	return q
+}
+
+// deliverTrades hands the trades of an exchange update to the trade handlers.
+// If any handler gets its trades in order, the batches are first put back in
+// nonce order. Batches without trades take part in that too, since they fill
+// the gaps between the others. Each sequence starts with the earliest batch
+// that arrives within tradeSequenceSettleTime of the first one. A batch that
+// arrives after the ordered handlers have moved on past it is reported as a
+// NonceGap stream event instead.
+func (c *Client) deliverTrades(b tradeBatch) {
+	handlers, ordered := c.handlers()
+	if len(handlers) == 0 {
+		return
+	}
+	if !ordered {
+		c.dispatchTrades(handlers, b)
+		return
+	}
+
+	// Dispatching blocks while a queue is full, so it happens after the
+	// sequences mutex is released, which lets the handlers unsubscribe in the
+	// meantime. The dispatch mutex keeps the batches in order instead.
+	c.dispatchMux.Lock()
+	defer c.dispatchMux.Unlock()
+
+	c.sequencesMux.Lock()
+	if c.sequences == nil {
+		c.sequences = make(map[string]*tradeSequence)
+	}
+	s, ok := c.sequences[b.market]
+	if !ok {
+		// The sequence starts once the batches that were sent around the same
+		// time as this one have had a chance to arrive.
+		s = &tradeSequence{pending: make(map[uint]tradeBatch)}
+		c.sequences[b.market] = s
+	}
+
+	if s.started && b.nonce <= s.nonce {
+		// The ordered handlers have already moved on past the batch, either
+		// because it was given up on or because it arrived after the later
+		// ones that started the sequence. Only the other handlers still get
+		// it.
+		c.sequencesMux.Unlock()
+		if len(b.trades) > 0 {
+			c.emitStreamEvent(NonceGap, b.market, &NonceGapError{Market: b.market, First: b.nonce, Last: b.nonce, Late: true})
+		}
+		c.dispatchTrades(unorderedHandlers(handlers, c.OrderedDelivery), b)
+		return
+	}
+
+	s.pending[b.nonce] = b
+	batches := c.releaseTrades(b.market, s)
+	c.sequencesMux.Unlock()
+
+	for _, rb := range batches {
+		c.dispatchTrades(handlers, rb)
+	}
+}
+
+// unorderedHandlers returns the handlers that get their trades in any order.
+func unorderedHandlers(handlers []*tradeHandler, orderedDelivery bool) []*tradeHandler {
+	var unordered []*tradeHandler
+	if orderedDelivery {
+		return unordered
+	}
+	for _, th := range handlers {
+		if !th.ordered {
+			unordered = append(unordered, th)
+		}
+	}
+	return unordered
+}
+
+// releaseTrades returns the batches of the market's sequence that are next in
+// line and waits for the missing one, if any, or for the sequence to start.
+// The sequences mutex must be held by the caller.
+func (c *Client) releaseTrades(market string, s *tradeSequence) []tradeBatch {
+	batches := s.release()
+
+	if len(s.pending) == 0 {
+		if s.gapTimer != nil {
+			s.gapTimer.Stop()
+			s.gapTimer = nil
+		}
+		return batches
+	}
+
+	if s.gapTimer == nil {
+		wait := c.NonceGapTimeout
+		if !s.started && tradeSequenceSettleTime < wait {
+			wait = tradeSequenceSettleTime
+		}
+		s.gapTimer = time.AfterFunc(wait, func() { c.gapSkipped(market, s) })
+	}
+
+	return batches
+}
+
+// gapSkipped gives up on the batches that the market's sequence is missing,
+// or starts the sequence, and dispatches the batches that follow.
+func (c *Client) gapSkipped(market string, s *tradeSequence) {
+	c.dispatchMux.Lock()
+	defer c.dispatchMux.Unlock()
+
+	c.sequencesMux.Lock()
+	if c.sequences[market] != s {
+		// The sequence was discarded in the meantime.
+		c.sequencesMux.Unlock()
+		return
+	}
+
+	s.gapTimer = nil
+	if first, last, ok := s.skip(); ok {
+		c.emitStreamEvent(NonceGap, market, &NonceGapError{Market: market, First: first, Last: last})
+	}
+	batches := c.releaseTrades(market, s)
+	c.sequencesMux.Unlock()
+
+	handlers, _ := c.handlers()
+	for _, b := range batches {
+		c.dispatchTrades(handlers, b)
+	}
+}
+
+// dispatchTrades hands the trades to each handler, either through its queue or
+// in a goroutine per trade.
+func (c *Client) dispatchTrades(handlers []*tradeHandler, b tradeBatch) {
+	if len(b.trades) == 0 {
+		return
+	}
+
+	done := c.closing()
+
+	for _, th := range handlers {
+		if th.markets != nil && !th.markets[b.market] {
+			continue
+		}
+
+		if !th.ordered && !c.OrderedDelivery {
+			for _, t := range b.trades {
+				go th.h(t)
+			}
+			continue
+		}
+
+		q := c.queue(th, b.market)
+		if q == nil {
+			return
+		}
+
+		for _, t := range b.trades {
+			if !q.put(t, c.QueueOverflow, done, th.stop) {
+				c.reportError(&QueueOverflowError{Handler: th.id, Market: b.market, Trade: t})
+			}
+		}
+	}
+}
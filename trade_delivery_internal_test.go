@@ -0,0 +1,141 @@
+package bittrex
+
+import (
+	"testing"
+)
+
+func TestTradeQueue_put(t *testing.T) {
+	cases := map[string]struct {
+		policy     OverflowPolicy
+		expOK      []bool
+		expPrices  []float64
+		expDropped uint64
+	}{
+		"drop oldest": {
+			policy:     OverflowDropOldest,
+			expOK:      []bool{true, true, true},
+			expPrices:  []float64{2, 3},
+			expDropped: 1,
+		},
+		"error": {
+			policy:     OverflowError,
+			expOK:      []bool{true, true, false},
+			expPrices:  []float64{1, 2},
+			expDropped: 1,
+		},
+	}
+
+	for id, tc := range cases {
+		q := &tradeQueue{trades: make(chan Trade, 2)}
+
+		var oks []bool
+		for i := 1; i <= 3; i++ {
+			oks = append(oks, q.put(Trade{Price: float64(i)}, tc.policy, nil, nil))
+		}
+		close(q.trades)
+
+		var prices []float64
+		for tr := range q.trades {
+			prices = append(prices, tr.Price)
+		}
+
+		equals(t, id, tc.expOK, oks)
+		equals(t, id, tc.expPrices, prices)
+		equals(t, id, tc.expDropped, q.dropped)
+	}
+
+	// A blocked put gives up once the client is closed or the handler is
+	// unregistered.
+	q := &tradeQueue{trades: make(chan Trade, 1)}
+	closed := make(chan struct{})
+	close(closed)
+	equals(t, "block", true, q.put(Trade{Price: 1}, OverflowBlock, nil, nil))
+	equals(t, "block", true, q.put(Trade{Price: 2}, OverflowBlock, closed, nil))
+	equals(t, "block", true, q.put(Trade{Price: 3}, OverflowBlock, nil, closed))
+	equals(t, "block", 1, len(q.trades))
+	equals(t, "block", 1.0, (<-q.trades).Price)
+}
+
+func TestTradeSequence(t *testing.T) {
+	cases := map[string]struct {
+		start      uint
+		unstarted  bool
+		nonces     []uint
+		skip       bool
+		expSkipped []uint
+		expNonces  []uint
+		expLast    uint
+	}{
+		"in order": {
+			start:     4,
+			nonces:    []uint{5, 6},
+			expNonces: []uint{5, 6},
+			expLast:   6,
+		},
+		"out of order": {
+			start:     4,
+			nonces:    []uint{6, 5, 7},
+			expNonces: []uint{5, 6, 7},
+			expLast:   7,
+		},
+		"gap": {
+			start:   4,
+			nonces:  []uint{6, 7},
+			expLast: 4,
+		},
+		"gap skipped": {
+			start:      4,
+			nonces:     []uint{9, 7, 8},
+			skip:       true,
+			expSkipped: []uint{5, 6},
+			expNonces:  []uint{7, 8, 9},
+			expLast:    9,
+		},
+		"single gap skipped": {
+			start:      4,
+			nonces:     []uint{6},
+			skip:       true,
+			expSkipped: []uint{5, 5},
+			expNonces:  []uint{6},
+			expLast:    6,
+		},
+		"nothing to skip": {
+			start:   4,
+			skip:    true,
+			expLast: 4,
+		},
+		"not started": {
+			unstarted: true,
+			nonces:    []uint{6, 5},
+		},
+		"started with the earliest": {
+			unstarted: true,
+			nonces:    []uint{7, 5, 6},
+			skip:      true,
+			expNonces: []uint{5, 6, 7},
+			expLast:   7,
+		},
+	}
+
+	for id, tc := range cases {
+		s := &tradeSequence{nonce: tc.start, started: !tc.unstarted, pending: make(map[uint]tradeBatch)}
+		for _, n := range tc.nonces {
+			s.pending[n] = tradeBatch{nonce: n}
+		}
+		if tc.skip {
+			var skipped []uint
+			if first, last, ok := s.skip(); ok {
+				skipped = []uint{first, last}
+			}
+			equals(t, id, tc.expSkipped, skipped)
+		}
+
+		var nonces []uint
+		for _, b := range s.release() {
+			nonces = append(nonces, b.nonce)
+		}
+
+		equals(t, id, tc.expNonces, nonces)
+		equals(t, id, tc.expLast, s.nonce)
+	}
+}
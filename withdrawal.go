@@ -0,0 +1,209 @@
+package bittrex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Withdrawal represents a withdrawal from the user's account.
+type Withdrawal struct { // nolint: maligned
+	PaymentUUID    string `json:"PaymentUuid"`
+	Currency       string
+	Amount         float64
+	Address        string
+	Opened         time.Time
+	Authorized     bool
+	PendingPayment bool
+	TxCost         float64
+	TxID           string `json:"TxId"`
+	Canceled       bool
+	InvalidAddress bool
+}
+
+// UnmarshalJSON decodes a withdrawal, parsing its timestamp.
+func (w *Withdrawal) UnmarshalJSON(data []byte) error {
+	type alias Withdrawal
+	aux := struct {
+		*alias
+		Opened string
+	}{alias: (*alias)(w)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	w.Opened, err = parseTimestamp(aux.Opened)
+	if err != nil {
+		return errors.Wrap(err, "invalid Opened timestamp")
+	}
+
+	return nil
+}
+
+// WithdrawalDestination is a destination that withdrawals are allowed to be
+// sent to. An empty PaymentID only matches withdrawals without a payment ID.
+type WithdrawalDestination struct {
+	Currency  string
+	Address   string
+	PaymentID string
+}
+
+// WithdrawalPolicy restricts the withdrawals that a Client makes. It is a
+// safety net against bugs and leaked configuration, so anything that is not
+// explicitly allowed is refused. The policy must not be modified while
+// withdrawals are in progress.
+type WithdrawalPolicy struct {
+	// Allowed lists the destinations that withdrawals can be sent to.
+	// Currencies are compared case-insensitively, addresses and payment IDs
+	// exactly.
+	Allowed []WithdrawalDestination
+
+	// MaxAmounts maps currencies to the largest quantity a single withdrawal
+	// can move. Currencies are compared case-insensitively. The "*" entry, if
+	// present, applies to the currencies that are not listed. Withdrawals of
+	// currencies without a maximum are refused.
+	MaxAmounts map[string]float64
+
+	// DryRun indicates that withdrawals are signed but not sent. The signed
+	// request is returned instead.
+	DryRun bool
+
+	// AuditHandler is optional. It is called with an event for every
+	// withdrawal attempt, including refused ones, before Withdraw returns.
+	AuditHandler WithdrawalAuditHandler
+}
+
+// check returns the reason the specified withdrawal is refused, or an empty
+// string if it is allowed.
+func (p *WithdrawalPolicy) check(currency string, quantity float64, address, paymentID string) string {
+	if p == nil {
+		return "no withdrawal policy is configured"
+	}
+
+	// This also refuses NaN, which would pass every comparison below.
+	if !(quantity > 0) {
+		return "quantity must be positive"
+	}
+
+	allowed := false
+	for _, d := range p.Allowed {
+		if strings.EqualFold(d.Currency, currency) && d.Address == address && d.PaymentID == paymentID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "destination is not in the allowlist"
+	}
+
+	max, ok := p.maxAmount(currency)
+	if !ok {
+		return "no maximum amount is configured for the currency"
+	}
+	// This also refuses a NaN maximum.
+	if !(quantity <= max) {
+		return fmt.Sprintf("quantity exceeds the maximum of %s",
+			strconv.FormatFloat(max, 'f', -1, 64))
+	}
+
+	return ""
+}
+
+// maxAmount returns the largest quantity of the currency that a single
+// withdrawal can move. It reports false if there is no maximum for it.
+func (p *WithdrawalPolicy) maxAmount(currency string) (float64, bool) {
+	for c, max := range p.MaxAmounts {
+		if strings.EqualFold(c, currency) {
+			return max, true
+		}
+	}
+
+	max, ok := p.MaxAmounts["*"]
+	return max, ok
+}
+
+// WithdrawalOutcome represents what happened to a withdrawal attempt.
+type WithdrawalOutcome int
+
+func (o WithdrawalOutcome) String() string {
+	switch o {
+	case WithdrawalRefused:
+		return "REFUSED"
+	case WithdrawalSimulated:
+		return "SIMULATED"
+	case WithdrawalSent:
+		return "SENT"
+	case WithdrawalFailed:
+		return "FAILED"
+	default:
+		return "<invalid withdrawal outcome>"
+	}
+}
+
+const (
+	// InvalidWithdrawalOutcome is used as the default for the
+	// WithdrawalOutcome type to indicate a variable has not been explicitly
+	// set to one of the valid values.
+	InvalidWithdrawalOutcome WithdrawalOutcome = iota
+
+	// WithdrawalRefused means the policy did not allow the withdrawal.
+	WithdrawalRefused
+
+	// WithdrawalSimulated means the withdrawal was allowed, but only signed
+	// because the policy is in dry-run mode.
+	WithdrawalSimulated
+
+	// WithdrawalSent means Bittrex accepted the withdrawal.
+	WithdrawalSent
+
+	// WithdrawalFailed means the withdrawal was allowed, but the request
+	// failed. It may or may not have been applied; see the error.
+	WithdrawalFailed
+)
+
+// WithdrawalAuditEvent records a withdrawal attempt.
+type WithdrawalAuditEvent struct {
+	Time      time.Time
+	Currency  string
+	Quantity  float64
+	Address   string
+	PaymentID string
+	Outcome   WithdrawalOutcome
+
+	// ID is the UUID that Bittrex assigned to the withdrawal. It is only set
+	// if the withdrawal was sent.
+	ID string
+
+	// Err is the reason the withdrawal was refused or failed.
+	Err error
+}
+
+// WithdrawalAuditHandler processes a withdrawal audit event.
+type WithdrawalAuditHandler func(e WithdrawalAuditEvent)
+
+// SubmittedWithdrawal represents a withdrawal that was allowed by the policy
+// and either accepted by Bittrex or, in dry-run mode, signed.
+type SubmittedWithdrawal struct {
+	// ID is the UUID that Bittrex assigned to the withdrawal. It is empty in
+	// dry-run mode.
+	ID string
+
+	Currency  string
+	Quantity  float64
+	Address   string
+	PaymentID string
+
+	// DryRun indicates that the withdrawal was not sent.
+	DryRun bool
+
+	// Request is the signed request that would have been sent. It is only
+	// set in dry-run mode.
+	Request *http.Request
+}
@@ -0,0 +1,42 @@
+package bittrex_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/carterjones/bittrex"
+)
+
+func TestWithdrawal_UnmarshalJSON(t *testing.T) {
+	cases := map[string]struct {
+		in      string
+		exp     bittrex.Withdrawal
+		wantErr string
+	}{
+		"pending transaction": {
+			in: `{"PaymentUuid":"my-uuid","Currency":"BTC","Amount":17,"Opened":"2014-07-09T04:24:47.217","TxId":null}`,
+			exp: bittrex.Withdrawal{
+				PaymentUUID: "my-uuid",
+				Currency:    "BTC",
+				Amount:      17,
+				Opened:      time.Date(2014, 7, 9, 4, 24, 47, 217000000, time.UTC),
+			},
+		},
+		"invalid timestamp": {
+			in:      `{"PaymentUuid":"my-uuid","Opened":"faketimestamp"}`,
+			wantErr: "invalid Opened timestamp",
+		},
+	}
+
+	for id, tc := range cases {
+		var act bittrex.Withdrawal
+		err := json.Unmarshal([]byte(tc.in), &act)
+		if tc.wantErr == "" {
+			equals(t, id, tc.exp, act)
+			ok(t, id, err)
+		} else {
+			errMatches(t, id, err, tc.wantErr)
+		}
+	}
+}